@@ -0,0 +1,373 @@
+// Command port-scan scans TCP ports with the same scanner used by the
+// provider, directly or through an SSH bastion.
+//
+//	$ port-scan -ports 22,80,443 -format json 192.168.1.0/24
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	scanner "github.com/picatz/terraform-provider-port-scan/internal/provider/port-scanner"
+	"golang.org/x/crypto/ssh"
+)
+
+// Output formats.
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatCSV   = "csv"
+	formatXML   = "xml"
+	formatGrep  = "grepable"
+	formatSARIF = "sarif"
+)
+
+// bastionPasswordEnv is the environment variable read for the SSH bastion
+// password, so it doesn't have to be passed as an argument.
+const bastionPasswordEnv = "PORT_SCAN_BASTION_PASSWORD"
+
+// config holds the parsed command line.
+type config struct {
+	targets []string
+	ports   []int
+	opts    scanner.Options
+	policy  scanner.ExposurePolicy
+
+	maxDuration        time.Duration
+	openOnly           bool
+	format             string
+	raiseOpenFileLimit bool
+
+	bastion               string
+	bastionUser           string
+	bastionKey            string
+	bastionPassword       string
+	bastionHostKey        string
+	insecureIgnoreHostKey bool
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run scans the targets given by the arguments, and returns the exit code.
+func run(args []string, stdout, stderr io.Writer) int {
+	c, err := parseFlags(args, stderr)
+	if err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		fmt.Fprintf(stderr, "port-scan: %s\n", err)
+		return 2
+	}
+
+	if err := scan(c, stdout, stderr); err != nil {
+		fmt.Fprintf(stderr, "port-scan: %s\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+func parseFlags(args []string, stderr io.Writer) (*config, error) {
+	c := &config{}
+
+	fs := flag.NewFlagSet("port-scan", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: port-scan [flags] target...\n\n")
+		fmt.Fprintf(fs.Output(), "Targets are IP addresses, hostnames or CIDR blocks.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	var (
+		ports       = fs.String("ports", "1-1024", "ports to scan, such as \"22,80,8000-8100\"")
+		allow       = fs.String("allow", "", "ports allowed to be open, every other open port is a sarif finding")
+		forbid      = fs.String("forbid", "", "ports never allowed to be open, reported as severe sarif findings")
+		suppress    = fs.String("suppress", "", "IDs of accepted known-dangerous services, such as \"rdp,redis\", whose sarif findings are suppressed")
+		timeoutMode = fs.String("timeout-mode", string(scanner.TimeoutModeFixed), "how the dial timeout is chosen, \"fixed\" or \"adaptive\"")
+		rate        = fs.Float64("rate", 0, "maximum connections per second across all hosts, 0 for no limit")
+		hostRate    = fs.Float64("host-rate", 0, "maximum connections per second to each host, 0 for no limit")
+		probers     = fs.String("probers", "", "probers run on open ports, such as \"tls,http\", one of "+strings.Join(scanner.DefaultRegistry.Names(), ", "))
+	)
+
+	fs.DurationVar(&c.opts.TimeoutPerPort, "timeout", scanner.DefaultTimeoutPerPort, "dial timeout for each port, the upper bound in adaptive mode")
+	fs.IntVar(&c.opts.Retry.Attempts, "retries", 1, "total number of attempts for ports that time out, including the first one")
+	fs.DurationVar(&c.opts.Retry.Backoff, "retry-backoff", 0, "delay before the first retry, doubled for every retry after it")
+	fs.IntVar(&c.opts.Confirmations, "confirmations", 1, "consecutive consistent results required before the state of a port is reported")
+	fs.DurationVar(&c.opts.Jitter, "jitter", 0, "upper bound of a random delay added before each probe")
+	fs.BoolVar(&c.opts.RandomizePorts, "randomize", false, "scan the ports in a random order")
+	fs.BoolVar(&c.opts.GrabBanners, "banners", false, "read the banner sent by services on open ports")
+	fs.DurationVar(&c.opts.BannerTimeout, "banner-timeout", scanner.DefaultBannerTimeout, "how long to wait for a banner")
+	fs.DurationVar(&c.opts.ProbeTimeout, "probe-timeout", 0, "how long each prober may take, defaults to -banner-timeout")
+
+	fs.DurationVar(&c.maxDuration, "max-duration", 0, "stop the scan after this duration, 0 for no limit")
+	fs.BoolVar(&c.openOnly, "open", false, "only report open ports")
+	fs.StringVar(&c.format, "format", formatTable, "output format, \"table\", \"json\", \"csv\", nmap \"xml\" or \"grepable\", or \"sarif\"")
+	fs.BoolVar(&c.raiseOpenFileLimit, "raise-open-file-limit", false, "raise the soft open file limit to the hard limit before scanning")
+
+	fs.StringVar(&c.bastion, "bastion", "", "SSH bastion address to scan from, such as \"192.168.1.1:22\"")
+	fs.StringVar(&c.bastionUser, "bastion-user", "root", "SSH bastion username")
+	fs.StringVar(&c.bastionKey, "bastion-key", "", "path to the PEM encoded SSH bastion private key")
+	fs.StringVar(&c.bastionPassword, "bastion-password", "", "SSH bastion password, defaults to $"+bastionPasswordEnv)
+	fs.StringVar(&c.bastionHostKey, "bastion-host-key", "", "base64 encoded SSH bastion host key")
+	fs.BoolVar(&c.insecureIgnoreHostKey, "insecure-ignore-host-key", false, "skip SSH bastion host key checking")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return nil, errors.New("no targets given")
+	}
+	c.targets = fs.Args()
+
+	var err error
+	if c.ports, err = scanner.ParsePorts(*ports); err != nil {
+		return nil, fmt.Errorf("invalid -ports: %s", err)
+	}
+
+	switch mode := scanner.TimeoutMode(*timeoutMode); mode {
+	case scanner.TimeoutModeFixed, scanner.TimeoutModeAdaptive:
+		c.opts.TimeoutMode = mode
+	default:
+		return nil, fmt.Errorf("invalid -timeout-mode %q, expected %q or %q", *timeoutMode, scanner.TimeoutModeFixed, scanner.TimeoutModeAdaptive)
+	}
+
+	switch c.format {
+	case formatTable, formatJSON, formatCSV, formatXML, formatGrep, formatSARIF:
+	default:
+		return nil, fmt.Errorf("invalid -format %q, expected %q, %q, %q, %q, %q or %q", c.format, formatTable, formatJSON, formatCSV, formatXML, formatGrep, formatSARIF)
+	}
+
+	if *allow != "" {
+		if c.policy.AllowedPorts, err = scanner.ParsePorts(*allow); err != nil {
+			return nil, fmt.Errorf("invalid -allow: %s", err)
+		}
+	}
+	if *forbid != "" {
+		if c.policy.ForbiddenPorts, err = scanner.ParsePorts(*forbid); err != nil {
+			return nil, fmt.Errorf("invalid -forbid: %s", err)
+		}
+	}
+
+	if *suppress != "" {
+		risks := map[string]bool{}
+		for _, risk := range scanner.Risks() {
+			risks[risk.ID] = true
+		}
+		c.policy.Suppressions = map[string]string{}
+		for _, id := range strings.Split(*suppress, ",") {
+			id = strings.TrimSpace(id)
+			if !risks[id] {
+				return nil, fmt.Errorf("invalid -suppress: unknown finding %q", id)
+			}
+			c.policy.Suppressions[id] = ""
+		}
+	}
+
+	if *probers != "" {
+		registered := map[string]bool{}
+		for _, name := range scanner.DefaultRegistry.Names() {
+			registered[name] = true
+		}
+		for _, name := range strings.Split(*probers, ",") {
+			name = strings.TrimSpace(name)
+			if !registered[name] {
+				return nil, fmt.Errorf("invalid -probers: unknown prober %q", name)
+			}
+			c.opts.Probers = append(c.opts.Probers, name)
+		}
+	}
+
+	if c.opts.TimeoutPerPort <= 0 {
+		return nil, errors.New("-timeout must be positive")
+	}
+	if c.opts.Retry.Attempts < 1 {
+		return nil, errors.New("-retries must be at least 1")
+	}
+	if c.opts.Confirmations < 1 {
+		return nil, errors.New("-confirmations must be at least 1")
+	}
+
+	if *rate > 0 {
+		c.opts.RateLimiters = append(c.opts.RateLimiters, scanner.NewRateLimiter(*rate, 1))
+	}
+	if *hostRate > 0 {
+		c.opts.HostRateLimiters = append(c.opts.HostRateLimiters, scanner.NewHostRateLimiter(*hostRate, 1))
+	}
+
+	if c.bastionPassword == "" {
+		c.bastionPassword = os.Getenv(bastionPasswordEnv)
+	}
+	if c.bastion != "" {
+		if (c.bastionKey == "") == (c.bastionPassword == "") {
+			return nil, fmt.Errorf("exactly one of -bastion-key or -bastion-password (or $%s) is required with -bastion", bastionPasswordEnv)
+		}
+		if (c.bastionHostKey == "") == !c.insecureIgnoreHostKey {
+			return nil, errors.New("exactly one of -bastion-host-key or -insecure-ignore-host-key is required with -bastion")
+		}
+	}
+
+	return c, nil
+}
+
+// newDialer returns a direct dialer, or an SSH bastion dialer when -bastion
+// is given. The dialer must be closed by the caller.
+func newDialer(c *config) (scanner.Dialer, error) {
+	if c.bastion == "" {
+		return scanner.NewDialer(), nil
+	}
+
+	sshClientConfig := &ssh.ClientConfig{
+		Timeout: 2 * time.Minute,
+		User:    c.bastionUser,
+	}
+
+	if c.insecureIgnoreHostKey {
+		sshClientConfig.HostKeyCallback = ssh.InsecureIgnoreHostKey()
+	} else {
+		sshClientConfig.HostKeyCallback = scanner.SSHHostKeyCallback(c.bastionHostKey)
+	}
+
+	if c.bastionKey != "" {
+		key, err := ioutil.ReadFile(c.bastionKey)
+		if err != nil {
+			return nil, err
+		}
+		authMethod, err := scanner.SSHPrivateKeyAuth(string(key))
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %s", c.bastionKey, err)
+		}
+		sshClientConfig.Auth = append(sshClientConfig.Auth, authMethod)
+	} else {
+		sshClientConfig.Auth = append(sshClientConfig.Auth, ssh.Password(c.bastionPassword))
+	}
+
+	return scanner.NewSSHBastionScanner(c.bastion, sshClientConfig)
+}
+
+func scan(c *config, stdout, stderr io.Writer) error {
+	ips, err := scanner.ExpandTargets(c.targets)
+	if err != nil {
+		return err
+	}
+
+	if c.raiseOpenFileLimit {
+		if _, err := scanner.RaiseOpenFileLimit(); err != nil {
+			fmt.Fprintf(stderr, "port-scan: unable to raise the open file limit: %s\n", err)
+		}
+	}
+
+	dialer, err := newDialer(c)
+	if err != nil {
+		return err
+	}
+	defer dialer.Close()
+
+	ctx := context.Background()
+	if c.maxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.maxDuration)
+		defer cancel()
+	}
+
+	report := &scanner.Report{
+		StartedAt: time.Now().UTC(),
+		Dialer:    "direct",
+		Results:   []scanner.PortScanResult{},
+	}
+	if c.bastion != "" {
+		report.Dialer = "ssh_bastion"
+	}
+
+	for result := range scanner.RunHostsContext(ctx, dialer, ips, c.ports, &c.opts) {
+		if c.openOnly && !result.Open {
+			continue
+		}
+		report.Results = append(report.Results, result)
+	}
+	report.Duration = time.Since(report.StartedAt)
+
+	if ctx.Err() != nil {
+		fmt.Fprintf(stderr, "port-scan: scan stopped after -max-duration %s, results are partial\n", c.maxDuration)
+	}
+
+	sortResults(report.Results, ips)
+
+	switch c.format {
+	case formatJSON:
+		return scanner.WriteJSON(stdout, report)
+	case formatCSV:
+		return scanner.WriteCSV(stdout, report)
+	case formatXML:
+		return scanner.WriteNmapXML(stdout, report)
+	case formatGrep:
+		return scanner.WriteNmapGrepable(stdout, report)
+	case formatSARIF:
+		return scanner.WriteSARIF(stdout, report, &c.policy)
+	default:
+		return writeTable(stdout, report)
+	}
+}
+
+// sortResults sorts the results by the order of the expanded targets, then by port.
+func sortResults(results []scanner.PortScanResult, ips []string) {
+	order := make(map[string]int, len(ips))
+	for i, ip := range ips {
+		order[ip] = i
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].IP != results[j].IP {
+			return order[results[i].IP] < order[results[j].IP]
+		}
+		return results[i].Port < results[j].Port
+	})
+}
+
+// writeTable writes the results as aligned columns for humans.
+func writeTable(w io.Writer, report *scanner.Report) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "IP\tPORT\tSTATE\tSERVICE\tLATENCY\tBANNER")
+	for _, result := range report.Results {
+		latency := "-"
+		if result.Latency > 0 {
+			latency = result.Latency.Round(time.Microsecond).String()
+		}
+		fmt.Fprintf(tw, "%s\t%d/tcp\t%s\t%s\t%s\t%s\n",
+			result.IP,
+			result.Port,
+			result.State,
+			dash(result.ServiceName()),
+			latency,
+			dash(result.Banner),
+		)
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "\n%d results in %s\n", len(report.Results), report.Duration.Round(time.Millisecond))
+	return err
+}
+
+// dash returns "-" for empty table cells.
+func dash(s string) string {
+	if strings.TrimSpace(s) == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	scanner "github.com/picatz/terraform-provider-port-scan/internal/provider/port-scanner"
+)
+
+func Test_run_json(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	args := []string{"-ports", strconv.Itoa(port), "-format", "json", "127.0.0.1"}
+	if code := run(args, stdout, stderr); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr)
+	}
+
+	report, err := scanner.ReadJSON(stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Results) != 1 {
+		t.Fatalf("Expected 1 result, got %+v", report.Results)
+	}
+	if result := report.Results[0]; result.IP != "127.0.0.1" || result.Port != port || !result.Open {
+		t.Fatalf("Expected port %d to be open, got %+v", port, result)
+	}
+	if report.Dialer != "direct" {
+		t.Errorf("Expected the direct dialer, got %q", report.Dialer)
+	}
+}
+
+func Test_run_invalidArguments(t *testing.T) {
+	for _, args := range [][]string{
+		{},
+		{"-ports", "0", "127.0.0.1"},
+		{"-format", "yaml", "127.0.0.1"},
+		{"-timeout-mode", "slow", "127.0.0.1"},
+		{"-probers", "tls,ftp", "127.0.0.1"},
+		{"-suppress", "rdp,ssh", "127.0.0.1"},
+		{"-bastion", "127.0.0.1:22", "127.0.0.1"},
+	} {
+		stderr := &bytes.Buffer{}
+		if code := run(args, &bytes.Buffer{}, stderr); code != 2 {
+			t.Errorf("Expected exit code 2 for %q, got %d", args, code)
+		}
+		if !strings.Contains(stderr.String(), "port-scan: ") {
+			t.Errorf("Expected an error for %q, got %q", args, stderr)
+		}
+	}
+}
+
+func Test_run_sarif(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	port := strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	if code := run([]string{"-ports", port, "-format", "sarif", "127.0.0.1"}, stdout, stderr); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr)
+	}
+	if !strings.Contains(stdout.String(), `"uri": "tcp://127.0.0.1:`+port+`"`) {
+		t.Errorf("Expected the open port to be a finding without an -allow policy, got:\n%s", stdout)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"-ports", port, "-format", "sarif", "-allow", port, "127.0.0.1"}, stdout, stderr); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr)
+	}
+	if !strings.Contains(stdout.String(), `"results": []`) {
+		t.Errorf("Expected no findings for an allowed port, got:\n%s", stdout)
+	}
+}
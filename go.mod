@@ -5,5 +5,6 @@ go 1.14
 require (
 	github.com/hashicorp/terraform-plugin-sdk v1.16.0
 	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
-	golang.org/x/sync v0.0.0-20200625203802-6e8e738ad208
+	golang.org/x/net v0.0.0-20200707034311-ab3426394381
+	golang.org/x/sys v0.0.0-20200523222454-059865788121
 )
@@ -0,0 +1,201 @@
+package provider
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	scanner "github.com/picatz/terraform-provider-port-scan/internal/provider/port-scanner"
+)
+
+// cachedOptions are the arguments, besides the ones the ID is derived from,
+// that change the results of a scan, so they're part of the cache key.
+var cachedOptions = []string{
+	"timeout_per_port",
+	"timeout_mode",
+	"retry_attempts",
+	"retry_backoff",
+	"confirmations",
+	"grab_banners",
+	"banner_timeout",
+	"probers",
+	"probe_timeout",
+}
+
+// cacheEntry is a complete scan stored in the cache.
+type cacheEntry struct {
+	ScannedAt time.Time     `json:"scanned_at"`
+	Duration  time.Duration `json:"duration"`
+	// TTL is the cache_ttl the scan was stored with, after which it's swept
+	// from the cache.
+	TTL     time.Duration            `json:"ttl,omitempty"`
+	Results []scanner.PortScanResult `json:"results"`
+}
+
+// resultCache stores the results of complete scans on disk, so data sources
+// read during both plan and apply, or by repeated runs, don't scan twice.
+type resultCache struct {
+	dir string
+	ttl time.Duration
+}
+
+// newResultCache creates the cache directory if needed.
+func newResultCache(dir string, ttl time.Duration) (*resultCache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("creating cache directory: %s", err)
+	}
+	return &resultCache{dir: dir, ttl: ttl}, nil
+}
+
+func (c *resultCache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// get returns the cached scan for the key, unless it's older than the ttl, in
+// which case it's removed from the cache.
+func (c *resultCache) get(key string, ttl time.Duration) (*cacheEntry, bool) {
+	data, err := ioutil.ReadFile(c.path(key))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("[WARN] unable to read cached scan: %s", err)
+		}
+		return nil, false
+	}
+
+	entry := &cacheEntry{}
+	if err := json.Unmarshal(data, entry); err != nil {
+		log.Printf("[WARN] ignoring invalid cached scan %s: %s", c.path(key), err)
+		return nil, false
+	}
+
+	if time.Since(entry.ScannedAt) > ttl {
+		c.remove(c.path(key))
+		return nil, false
+	}
+
+	return entry, true
+}
+
+// put stores the scan for the key, replacing the file atomically so
+// concurrent readers never see a partial entry, and sweeps the expired scans
+// of other keys, which might never be read again.
+func (c *resultCache) put(key string, entry *cacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(c.path(key), data); err != nil {
+		return err
+	}
+
+	c.sweep(c.path(key))
+	return nil
+}
+
+// sweep removes the scans older than the ttl they were stored with, or the
+// default ttl of the cache for entries without one, besides the one just
+// stored.
+func (c *resultCache) sweep(stored string) {
+	paths, err := filepath.Glob(filepath.Join(c.dir, "*.json"))
+	if err != nil {
+		log.Printf("[WARN] unable to sweep the cache: %s", err)
+		return
+	}
+
+	for _, path := range paths {
+		if path == stored {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		entry := &cacheEntry{}
+		if err := json.Unmarshal(data, entry); err != nil {
+			continue
+		}
+
+		ttl := entry.TTL
+		if ttl <= 0 {
+			ttl = c.ttl
+		}
+		if time.Since(entry.ScannedAt) > ttl {
+			c.remove(path)
+		}
+	}
+}
+
+// remove deletes an expired scan, which another reader might have already
+// removed.
+func (c *resultCache) remove(path string) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.Printf("[WARN] unable to remove expired cached scan: %s", err)
+	}
+}
+
+// cacheKey derives the cache key from the ID of the data source, which covers
+// the target, ports and dialer, and the other options changing the results.
+func cacheKey(d *schema.ResourceData) string {
+	options := []string{d.Id()}
+	for _, key := range cachedOptions {
+		if v, ok := d.GetOk(key); ok {
+			options = append(options, fmt.Sprintf("%s=%v", key, v))
+		}
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(options, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// cacheTTL returns the data source's cache_ttl, or the provider's default.
+func (c *providerConfig) cacheTTL(d *schema.ResourceData) time.Duration {
+	if v, ok := d.GetOk("cache_ttl"); ok {
+		ttl, _ := time.ParseDuration(v.(string))
+		return ttl
+	}
+	return c.cache.ttl
+}
+
+// cachedScan returns the cached scan for the data source, if caching is
+// enabled and it isn't bypassed.
+func (c *providerConfig) cachedScan(d *schema.ResourceData) (*cacheEntry, bool) {
+	if c.cache == nil || d.Get("bypass_cache").(bool) {
+		return nil, false
+	}
+
+	ttl := c.cacheTTL(d)
+	if ttl <= 0 {
+		return nil, false
+	}
+
+	return c.cache.get(cacheKey(d), ttl)
+}
+
+// cacheScan stores the scan for the data source, if caching is enabled.
+// Failing to store it isn't an error, since the scan itself succeeded.
+func (c *providerConfig) cacheScan(d *schema.ResourceData, entry *cacheEntry) {
+	if c.cache == nil {
+		return
+	}
+
+	ttl := c.cacheTTL(d)
+	if ttl <= 0 {
+		return
+	}
+
+	entry.TTL = ttl
+	if err := c.cache.put(cacheKey(d), entry); err != nil {
+		log.Printf("[WARN] unable to cache scan: %s", err)
+	}
+}
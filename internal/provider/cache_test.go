@@ -0,0 +1,111 @@
+package provider
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	scanner "github.com/picatz/terraform-provider-port-scan/internal/provider/port-scanner"
+)
+
+func testResultCache(t *testing.T) *resultCache {
+	dir, err := ioutil.TempDir("", "port-scan-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	cache, err := newResultCache(dir, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cache
+}
+
+func Test_resultCache(t *testing.T) {
+	cache := testResultCache(t)
+
+	if _, ok := cache.get("missing", time.Minute); ok {
+		t.Fatal("Expected a cache miss for a missing key")
+	}
+
+	entry := &cacheEntry{
+		ScannedAt: time.Now().Add(-time.Minute),
+		Duration:  time.Second,
+		Results:   []scanner.PortScanResult{{IP: "127.0.0.1", Port: 22, Open: true, State: scanner.PortStateOpen, Attempts: 1}},
+	}
+	if err := cache.put("key", entry); err != nil {
+		t.Fatal(err)
+	}
+
+	cached, ok := cache.get("key", time.Hour)
+	if !ok {
+		t.Fatal("Expected a cache hit")
+	}
+	if len(cached.Results) != 1 || !cached.Results[0].Open || cached.Duration != time.Second {
+		t.Errorf("Expected the cached scan to match, got %+v", cached)
+	}
+
+	if _, ok := cache.get("key", time.Second); ok {
+		t.Error("Expected a cache miss for an expired entry")
+	}
+}
+
+func Test_resultCache_expired(t *testing.T) {
+	cache := testResultCache(t)
+
+	stale := &cacheEntry{ScannedAt: time.Now().Add(-time.Hour), TTL: time.Minute}
+	for _, key := range []string{"read", "unread"} {
+		if err := cache.put(key, stale); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, ok := cache.get("read", time.Minute); ok {
+		t.Fatal("Expected a cache miss for an expired entry")
+	}
+	if _, err := os.Stat(cache.path("read")); !os.IsNotExist(err) {
+		t.Errorf("Expected the expired entry to be removed when read, got %v", err)
+	}
+
+	if err := cache.put("fresh", &cacheEntry{ScannedAt: time.Now(), TTL: time.Minute}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(cache.path("unread")); !os.IsNotExist(err) {
+		t.Errorf("Expected the expired entry to be swept when storing another one, got %v", err)
+	}
+	if _, ok := cache.get("fresh", time.Minute); !ok {
+		t.Error("Expected the fresh entry to be kept")
+	}
+}
+
+func Test_dataSourcePortScanRead_cache(t *testing.T) {
+	meta := &providerConfig{cache: testResultCache(t)}
+
+	config := map[string]interface{}{
+		"ip_address": "127.0.0.1",
+		"port":       1,
+	}
+
+	for i, want := range []bool{false, true} {
+		d := schema.TestResourceDataRaw(t, dataSourcePortScan().Schema, config)
+		if err := dataSourcePortScanRead(d, meta); err != nil {
+			t.Fatal(err)
+		}
+		if cached := d.Get("cached").(bool); cached != want {
+			t.Errorf("Expected read %d to have cached = %v, got %v", i, want, cached)
+		}
+	}
+
+	config["bypass_cache"] = true
+
+	d := schema.TestResourceDataRaw(t, dataSourcePortScan().Schema, config)
+	if err := dataSourcePortScanRead(d, meta); err != nil {
+		t.Fatal(err)
+	}
+	if d.Get("cached").(bool) {
+		t.Error("Expected bypass_cache to scan again")
+	}
+}
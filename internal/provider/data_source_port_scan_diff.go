@@ -0,0 +1,388 @@
+package provider
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
+	scanner "github.com/picatz/terraform-provider-port-scan/internal/provider/port-scanner"
+)
+
+// dataSourcePortScanDiff scans hosts and compares the results to a baseline,
+// so modules can gate on changes instead of the absolute state of the ports.
+func dataSourcePortScanDiff() *schema.Resource {
+	return &schema.Resource{
+		Read:     dataSourcePortScanDiffRead,
+		Timeouts: scanTimeouts(),
+		Schema: map[string]*schema.Schema{
+			"targets": {
+				ForceNew:    true,
+				Required:    true,
+				Type:        schema.TypeList,
+				MinItems:    1,
+				Description: "IP addresses, hostnames or CIDR blocks to scan",
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validateTarget,
+				},
+			},
+			// Baseline to compare the scan to
+			"baseline_file": {
+				ForceNew:      true,
+				Optional:      true,
+				Type:          schema.TypeString,
+				ConflictsWith: []string{"baseline_results"},
+				Description:   "Path of a JSON report of the baseline scan, as written by report_file or the port-scan command",
+			},
+			// as an attribute, the results of a port_scan data source can be
+			// assigned directly, so every attribute of results is accepted
+			"baseline_results": {
+				ForceNew:      true,
+				Optional:      true,
+				Type:          schema.TypeList,
+				ConflictsWith: []string{"baseline_file"},
+				ConfigMode:    schema.SchemaConfigModeAttr,
+				Description:   "Results of the baseline scan, such as the results of a port_scan data source",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"ip_address": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "Address of the host, the result applies to every host when empty",
+						},
+						"port": {
+							Type:         schema.TypeInt,
+							Required:     true,
+							ValidateFunc: validation.IsPortNumber,
+						},
+						"state": {
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validation.StringInSlice([]string{string(scanner.PortStateOpen), string(scanner.PortStateClosed), string(scanner.PortStateFiltered)}, false),
+						},
+						// the other attributes of results are accepted, and ignored
+						"protocol": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"latency_ms": {
+							Type:     schema.TypeFloat,
+							Optional: true,
+						},
+						"error": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"service": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"banner": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"probes": {
+							Type:       schema.TypeList,
+							Optional:   true,
+							ConfigMode: schema.SchemaConfigModeAttr,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"prober": {
+										Type:     schema.TypeString,
+										Optional: true,
+									},
+									"name": {
+										Type:     schema.TypeString,
+										Optional: true,
+									},
+									"value": {
+										Type:     schema.TypeString,
+										Optional: true,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			"fail_on_change": {
+				ForceNew:    true,
+				Optional:    true,
+				Type:        schema.TypeBool,
+				Default:     false,
+				Description: "Return an error listing the ports opened or closed since the baseline",
+			},
+			"port": {
+				ForceNew:      true,
+				Optional:      true,
+				Type:          schema.TypeInt,
+				ValidateFunc:  validation.IsPortNumber,
+				ConflictsWith: []string{"ports", "from_port", "to_port"},
+			},
+			"ports": {
+				ForceNew:      true,
+				Optional:      true,
+				Type:          schema.TypeList,
+				MinItems:      1,
+				ConflictsWith: []string{"port", "from_port", "to_port"},
+				Elem: &schema.Schema{
+					Type:         schema.TypeInt,
+					ValidateFunc: validation.IsPortNumber,
+				},
+			},
+			"from_port": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeInt,
+				Default:      1,
+				ValidateFunc: validation.IsPortNumber,
+			},
+			"to_port": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeInt,
+				Default:      1024,
+				ValidateFunc: validation.IsPortNumber,
+			},
+			// Optional timeout controls
+			"timeout_per_port": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeString,
+				Default:      scanner.DefaultTimeoutPerPort.String(),
+				ValidateFunc: validateDuration,
+				Description:  "Dial timeout for each port, or the upper bound for adaptive timeouts",
+			},
+			"timeout_mode": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeString,
+				Default:      string(scanner.TimeoutModeFixed),
+				ValidateFunc: validation.StringInSlice([]string{string(scanner.TimeoutModeFixed), string(scanner.TimeoutModeAdaptive)}, false),
+				Description:  "Either \"fixed\" to always use timeout_per_port, or \"adaptive\" to derive timeouts from measured round trip times",
+			},
+			// Optional retry controls
+			"retry_attempts": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeInt,
+				Default:      1,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "Total number of attempts for ports that time out, including the first one",
+			},
+			"retry_backoff": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeString,
+				Default:      "500ms",
+				ValidateFunc: validateDuration,
+				Description:  "Delay before the first retry, doubled for every retry after it",
+			},
+			"confirmations": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeInt,
+				Default:      1,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "Number of consecutive consistent results required before the state of a port is reported",
+			},
+			// Optional scan deadline
+			"max_scan_duration": maxScanDurationSchema(),
+			"timeouts":          timeoutsSchema(),
+			// Optional report of the scan, which can be the next baseline
+			"report_file":   reportFileSchema(),
+			"report_format": reportFormatSchema(),
+			// Optional SSH Bastion
+			"ssh_bastion": sshBastionSchema(),
+			// Computed fields
+			"ip_addresses": {
+				Computed:    true,
+				Type:        schema.TypeList,
+				Description: "Addresses the targets expanded to, in the order they were given",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"changed": {
+				Computed:    true,
+				Type:        schema.TypeBool,
+				Description: "Whether any port was opened or closed since the baseline",
+			},
+			"opened_endpoints": {
+				Computed:    true,
+				Type:        schema.TypeList,
+				Description: "Ports opened since the baseline as \"ip:port\" endpoints",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"closed_endpoints": {
+				Computed:    true,
+				Type:        schema.TypeList,
+				Description: "Ports closed since the baseline as \"ip:port\" endpoints",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"hosts": {
+				Computed:    true,
+				Type:        schema.TypeList,
+				Description: "Differences for each address",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"ip_address": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"changed": {
+							Type:     schema.TypeBool,
+							Computed: true,
+						},
+						"opened_ports": {
+							Type:     schema.TypeList,
+							Computed: true,
+							Elem: &schema.Schema{
+								Type: schema.TypeInt,
+							},
+						},
+						"closed_ports": {
+							Type:     schema.TypeList,
+							Computed: true,
+							Elem: &schema.Schema{
+								Type: schema.TypeInt,
+							},
+						},
+						"state_changes": {
+							Type:     schema.TypeList,
+							Computed: true,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"port": {
+										Type:     schema.TypeInt,
+										Computed: true,
+									},
+									"previous_state": {
+										Type:     schema.TypeString,
+										Computed: true,
+									},
+									"state": {
+										Type:     schema.TypeString,
+										Computed: true,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourcePortScanDiffRead(d *schema.ResourceData, meta interface{}) error {
+	targets := []string{}
+	for _, target := range d.Get("targets").([]interface{}) {
+		if target != nil {
+			targets = append(targets, target.(string))
+		}
+	}
+
+	if err := validatePortRange(d); err != nil {
+		return err
+	}
+
+	ipAddresses, err := scanner.ExpandTargets(targets)
+	if err != nil {
+		return err
+	}
+
+	b, err := readBaseline(d)
+	if err != nil {
+		return fmt.Errorf("reading baseline: %s", err)
+	}
+
+	// ports open in the baseline are always scanned, so closing them is detected
+	ports := uniquePorts(portsToScan(d), b.openPorts(ipAddresses))
+
+	if err := meta.(*providerConfig).validateProbes(len(ipAddresses), len(ports)); err != nil {
+		return err
+	}
+
+	d.SetId(scanID(ipAddresses, "tcp", ports, dialerIdentity(d)))
+
+	start := time.Now()
+
+	results, err := boundedScan(d, meta, ipAddresses, ports)
+	if err != nil {
+		return err
+	}
+
+	if err := writeReportFile(d, start, time.Since(start), results); err != nil {
+		return err
+	}
+
+	var (
+		changed  = false
+		opened   = []string{}
+		closed   = []string{}
+		hosts    = []interface{}{}
+		problems = []string{}
+	)
+
+	for _, diff := range diffResults(b, ipAddresses, results) {
+		changes := []interface{}{}
+		for _, change := range diff.changes {
+			changes = append(changes, map[string]interface{}{
+				"port":           change.port,
+				"previous_state": string(change.previous),
+				"state":          string(change.current),
+			})
+		}
+
+		for _, port := range diff.opened {
+			opened = append(opened, net.JoinHostPort(diff.ip, strconv.Itoa(port)))
+		}
+		for _, port := range diff.closed {
+			closed = append(closed, net.JoinHostPort(diff.ip, strconv.Itoa(port)))
+		}
+
+		if diff.changed() {
+			changed = true
+			problems = append(problems, fmt.Sprintf("%s opened ports: [%s], closed ports: [%s]", diff.ip, joinInts(diff.opened), joinInts(diff.closed)))
+		}
+
+		hosts = append(hosts, map[string]interface{}{
+			"ip_address":    diff.ip,
+			"changed":       diff.changed(),
+			"opened_ports":  diff.opened,
+			"closed_ports":  diff.closed,
+			"state_changes": changes,
+		})
+	}
+
+	if err := d.Set("ip_addresses", ipAddresses); err != nil {
+		return err
+	}
+	if err := d.Set("changed", changed); err != nil {
+		return err
+	}
+	if err := d.Set("opened_endpoints", opened); err != nil {
+		return err
+	}
+	if err := d.Set("closed_endpoints", closed); err != nil {
+		return err
+	}
+	if err := d.Set("hosts", hosts); err != nil {
+		return err
+	}
+
+	if changed && d.Get("fail_on_change").(bool) {
+		return fmt.Errorf("ports changed since the baseline: %s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
@@ -0,0 +1,358 @@
+package provider
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
+	scanner "github.com/picatz/terraform-provider-port-scan/internal/provider/port-scanner"
+)
+
+// dataSourcePortScanFirewall scans the ports allowed by declared ingress
+// rules, plus a sample of the ports they don't allow, to verify the rules
+// match what's actually reachable.
+func dataSourcePortScanFirewall() *schema.Resource {
+	return &schema.Resource{
+		Read:     dataSourcePortScanFirewallRead,
+		Timeouts: scanTimeouts(),
+		Schema: map[string]*schema.Schema{
+			"targets": {
+				ForceNew:    true,
+				Required:    true,
+				Type:        schema.TypeList,
+				MinItems:    1,
+				Description: "IP addresses, hostnames or CIDR blocks the rules apply to",
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validateTarget,
+				},
+			},
+			"rule": {
+				ForceNew:    true,
+				Required:    true,
+				Type:        schema.TypeList,
+				MinItems:    1,
+				Description: "Declared ingress rules, such as the rules of a security group",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"description": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"from_port": {
+							Type:         schema.TypeInt,
+							Required:     true,
+							ValidateFunc: validation.IsPortNumberOrZero,
+							Description:  "Start of the allowed port range, ignored for rules allowing all protocols",
+						},
+						"to_port": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							ValidateFunc: validation.IsPortNumberOrZero,
+							Description:  "End of the allowed port range, defaults to from_port",
+						},
+						"protocol": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      "tcp",
+							ValidateFunc: validation.StringInSlice([]string{"tcp", "-1", "all"}, false),
+							Description:  "Either \"tcp\", or \"-1\" or \"all\" for rules allowing all protocols, and so every TCP port",
+						},
+						// Computed fields
+						"effective": {
+							Type:     schema.TypeBool,
+							Computed: true,
+						},
+						"state": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"open_ports": {
+							Type:     schema.TypeList,
+							Computed: true,
+							Elem: &schema.Schema{
+								Type: schema.TypeInt,
+							},
+						},
+					},
+				},
+			},
+			// Undeclared ports checked for leaks
+			"sample_size": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeInt,
+				Default:      100,
+				ValidateFunc: validation.IntAtLeast(0),
+				Description:  "Number of ports the rules don't allow to check for leaks",
+			},
+			"sample_ports": {
+				ForceNew:    true,
+				Optional:    true,
+				Type:        schema.TypeList,
+				Description: "Additional ports to check for leaks, ignored when a rule allows them",
+				Elem: &schema.Schema{
+					Type:         schema.TypeInt,
+					ValidateFunc: validation.IsPortNumber,
+				},
+			},
+			"fail_on_leak": {
+				ForceNew:    true,
+				Optional:    true,
+				Type:        schema.TypeBool,
+				Default:     false,
+				Description: "Return an error listing the open ports no rule allows",
+			},
+			"fail_on_ineffective_rule": {
+				ForceNew:    true,
+				Optional:    true,
+				Type:        schema.TypeBool,
+				Default:     false,
+				Description: "Return an error listing the rules without any open port",
+			},
+			// Optional timeout controls
+			"timeout_per_port": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeString,
+				Default:      scanner.DefaultTimeoutPerPort.String(),
+				ValidateFunc: validateDuration,
+				Description:  "Dial timeout for each port, or the upper bound for adaptive timeouts",
+			},
+			"timeout_mode": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeString,
+				Default:      string(scanner.TimeoutModeFixed),
+				ValidateFunc: validation.StringInSlice([]string{string(scanner.TimeoutModeFixed), string(scanner.TimeoutModeAdaptive)}, false),
+				Description:  "Either \"fixed\" to always use timeout_per_port, or \"adaptive\" to derive timeouts from measured round trip times",
+			},
+			// Optional retry controls
+			"retry_attempts": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeInt,
+				Default:      1,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "Total number of attempts for ports that time out, including the first one",
+			},
+			"retry_backoff": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeString,
+				Default:      "500ms",
+				ValidateFunc: validateDuration,
+				Description:  "Delay before the first retry, doubled for every retry after it",
+			},
+			"confirmations": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeInt,
+				Default:      1,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "Number of consecutive consistent results required before the state of a port is reported",
+			},
+			// Optional scan deadline
+			"max_scan_duration": maxScanDurationSchema(),
+			"timeouts":          timeoutsSchema(),
+			// Optional report of the scan
+			"report_file":   reportFileSchema(),
+			"report_format": reportFormatSchema(),
+			// Optional SSH Bastion
+			"ssh_bastion": sshBastionSchema(),
+			// Computed fields
+			"passed": {
+				Computed:    true,
+				Type:        schema.TypeBool,
+				Description: "Whether every rule is effective and no undeclared port is open",
+			},
+			"ip_addresses": {
+				Computed:    true,
+				Type:        schema.TypeList,
+				Description: "Addresses the targets expanded to, in the order they were given",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"sampled_ports": {
+				Computed:    true,
+				Type:        schema.TypeList,
+				Description: "Undeclared ports checked for leaks",
+				Elem: &schema.Schema{
+					Type: schema.TypeInt,
+				},
+			},
+			"ineffective_rules": {
+				Computed:    true,
+				Type:        schema.TypeList,
+				Description: "Rules without any open port, as their port range and description",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"leaked_ports": {
+				Computed:    true,
+				Type:        schema.TypeList,
+				Description: "Open ports no rule allows",
+				Elem: &schema.Schema{
+					Type: schema.TypeInt,
+				},
+			},
+			"leaked_endpoints": {
+				Computed:    true,
+				Type:        schema.TypeList,
+				Description: "Open ports no rule allows, as \"ip:port\" endpoints",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+		},
+	}
+}
+
+// firewallRules reads the rule blocks.
+func firewallRules(d *schema.ResourceData) ([]*firewallRule, error) {
+	rules := []*firewallRule{}
+
+	for i, v := range d.Get("rule").([]interface{}) {
+		block := v.(map[string]interface{})
+
+		rule := &firewallRule{
+			description: block["description"].(string),
+			from:        block["from_port"].(int),
+			to:          block["to_port"].(int),
+		}
+
+		// like security group rules, rules allowing all protocols allow every
+		// port, whatever their port range, which is usually 0 to 0
+		if protocol := block["protocol"].(string); protocol == "-1" || protocol == "all" {
+			rule.from, rule.to = 1, 65535
+		}
+
+		if rule.from == 0 {
+			return nil, fmt.Errorf("rule.%d: from_port must be a port number for tcp rules", i)
+		}
+		if rule.to == 0 {
+			rule.to = rule.from
+		}
+		if rule.from > rule.to {
+			return nil, fmt.Errorf("rule.%d: from_port (%d) must not be greater than to_port (%d)", i, rule.from, rule.to)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+func dataSourcePortScanFirewallRead(d *schema.ResourceData, meta interface{}) error {
+	targets := []string{}
+	for _, target := range d.Get("targets").([]interface{}) {
+		if target != nil {
+			targets = append(targets, target.(string))
+		}
+	}
+
+	ipAddresses, err := scanner.ExpandTargets(targets)
+	if err != nil {
+		return err
+	}
+
+	rules, err := firewallRules(d)
+	if err != nil {
+		return err
+	}
+
+	sample := sampleUndeclaredPorts(rules, d.Get("sample_size").(int))
+	for _, port := range convertIntArr(d.Get("sample_ports").([]interface{})) {
+		if !declared(rules, port) {
+			sample = uniquePorts(sample, []int{port})
+		}
+	}
+
+	ports := uniquePorts(declaredPorts(rules), sample)
+
+	if err := meta.(*providerConfig).validateProbes(len(ipAddresses), len(ports)); err != nil {
+		return err
+	}
+
+	d.SetId(scanID(ipAddresses, "tcp", ports, dialerIdentity(d)))
+
+	start := time.Now()
+
+	results, err := boundedScan(d, meta, ipAddresses, ports)
+	if err != nil {
+		return err
+	}
+
+	if err := writeReportFile(d, start, time.Since(start), results); err != nil {
+		return err
+	}
+
+	ruleResults, leaks := verifyFirewall(rules, results)
+
+	var (
+		ruleBlocks  = d.Get("rule").([]interface{})
+		ineffective = []string{}
+	)
+
+	for i, ruleResult := range ruleResults {
+		block := ruleBlocks[i].(map[string]interface{})
+		block["effective"] = ruleResult.effective()
+		block["state"] = string(ruleResult.state)
+		block["open_ports"] = ruleResult.openPorts
+
+		if !ruleResult.effective() {
+			ineffective = append(ineffective, ruleResult.rule.String())
+		}
+	}
+
+	var (
+		leakedPorts     = []int{}
+		leakedEndpoints = []string{}
+	)
+
+	for _, leak := range leaks {
+		leakedPorts = uniquePorts(leakedPorts, []int{leak.Port})
+		leakedEndpoints = append(leakedEndpoints, net.JoinHostPort(leak.IP, strconv.Itoa(leak.Port)))
+	}
+
+	if err := d.Set("rule", ruleBlocks); err != nil {
+		return err
+	}
+	if err := d.Set("ip_addresses", ipAddresses); err != nil {
+		return err
+	}
+	if err := d.Set("sampled_ports", sample); err != nil {
+		return err
+	}
+	if err := d.Set("ineffective_rules", ineffective); err != nil {
+		return err
+	}
+	if err := d.Set("leaked_ports", leakedPorts); err != nil {
+		return err
+	}
+	if err := d.Set("leaked_endpoints", leakedEndpoints); err != nil {
+		return err
+	}
+	if err := d.Set("passed", len(ineffective) == 0 && len(leaks) == 0); err != nil {
+		return err
+	}
+
+	problems := []string{}
+	if len(leaks) > 0 && d.Get("fail_on_leak").(bool) {
+		problems = append(problems, fmt.Sprintf("open ports not allowed by any rule: [%s]", strings.Join(leakedEndpoints, ", ")))
+	}
+	if len(ineffective) > 0 && d.Get("fail_on_ineffective_rule").(bool) {
+		problems = append(problems, fmt.Sprintf("rules without any open port: [%s]", strings.Join(ineffective, ", ")))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("firewall rules don't match the scan: %s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
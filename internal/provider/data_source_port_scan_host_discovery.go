@@ -0,0 +1,199 @@
+package provider
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
+	scanner "github.com/picatz/terraform-provider-port-scan/internal/provider/port-scanner"
+)
+
+// dataSourcePortScanHostDiscovery sweeps addresses to find the live hosts,
+// before spending a full port scan on them.
+func dataSourcePortScanHostDiscovery() *schema.Resource {
+	return &schema.Resource{
+		Read:     dataSourcePortScanHostDiscoveryRead,
+		Timeouts: scanTimeouts(),
+		Schema: map[string]*schema.Schema{
+			"targets": {
+				ForceNew:    true,
+				Required:    true,
+				Type:        schema.TypeList,
+				MinItems:    1,
+				Description: "IP addresses, hostnames or CIDR blocks to sweep",
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validateTarget,
+				},
+			},
+			"ports": {
+				ForceNew:    true,
+				Optional:    true,
+				Type:        schema.TypeList,
+				Description: "TCP ports probed on each host, a host is alive if any of them is open or refuses the connection",
+				Elem: &schema.Schema{
+					Type:         schema.TypeInt,
+					ValidateFunc: validation.IsPortNumber,
+				},
+			},
+			"icmp": {
+				ForceNew:    true,
+				Optional:    true,
+				Type:        schema.TypeBool,
+				Default:     true,
+				Description: "Send ICMP echo requests when the provider is allowed to open raw sockets",
+			},
+			"timeout_per_port": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeString,
+				Default:      "1s",
+				ValidateFunc: validateDuration,
+				Description:  "Dial timeout for each port, and how long to wait for echo replies",
+			},
+			"retry_attempts": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeInt,
+				Default:      1,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "Total number of attempts for ports that time out, including the first one",
+			},
+			"max_scan_duration": maxScanDurationSchema(),
+			"timeouts":          timeoutsSchema(),
+			// Optional SSH Bastion, only used for the TCP probes
+			"ssh_bastion": sshBastionSchema(),
+			// Computed fields
+			"icmp_enabled": {
+				Computed:    true,
+				Type:        schema.TypeBool,
+				Description: "Whether ICMP echo requests were sent",
+			},
+			"live_hosts": {
+				Computed:    true,
+				Type:        schema.TypeList,
+				Description: "Addresses of the hosts found to be alive",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"hosts": {
+				Computed:    true,
+				Type:        schema.TypeList,
+				Description: "Discovery results for each address",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"ip_address": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"alive": {
+							Type:     schema.TypeBool,
+							Computed: true,
+						},
+						"reasons": {
+							Type:     schema.TypeList,
+							Computed: true,
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourcePortScanHostDiscoveryRead(d *schema.ResourceData, meta interface{}) error {
+	targets := []string{}
+	for _, target := range d.Get("targets").([]interface{}) {
+		if target != nil {
+			targets = append(targets, target.(string))
+		}
+	}
+
+	ipAddresses, err := scanner.ExpandTargets(targets)
+	if err != nil {
+		return err
+	}
+
+	ports := convertIntArr(d.Get("ports").([]interface{}))
+	if len(ports) == 0 {
+		ports = scanner.DefaultDiscoveryPorts
+	}
+
+	if err := meta.(*providerConfig).validateProbes(len(ipAddresses), len(ports)); err != nil {
+		return err
+	}
+
+	dialer, err := newDialer(d)
+	if err != nil {
+		return err
+	}
+	defer dialer.Close()
+
+	// ICMP can't go through the bastion, and needs raw sockets
+	icmp := false
+	if d.Get("icmp").(bool) {
+		if _, ok := d.GetOk("ssh_bastion"); ok {
+			log.Printf("[DEBUG] skipping ICMP host discovery through an SSH bastion")
+		} else if !scanner.ICMPAvailable() {
+			log.Printf("[DEBUG] skipping ICMP host discovery, raw sockets are not available")
+		} else {
+			icmp = true
+		}
+	}
+
+	// the ID doesn't depend on the order of the targets either
+	sortedTargets := append([]string{}, targets...)
+	sort.Strings(sortedTargets)
+
+	protocol := "tcp"
+	if d.Get("icmp").(bool) {
+		protocol = "icmp,tcp"
+	}
+
+	d.SetId(scanID(sortedTargets, protocol, ports, dialerIdentity(d)))
+
+	ctx, cancel, deadline := scanContext(d)
+	defer cancel()
+
+	results, err := scanner.DiscoverContext(ctx, dialer, ipAddresses, &scanner.DiscoveryOptions{
+		Options: *meta.(*providerConfig).scanOptions(d),
+		Ports:   ports,
+		ICMP:    icmp,
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("host discovery didn't complete within %s", deadline)
+		}
+		return err
+	}
+
+	var (
+		live  = []string{}
+		hosts = []interface{}{}
+	)
+
+	for _, result := range results {
+		if result.Alive {
+			live = append(live, result.IP)
+		}
+		hosts = append(hosts, map[string]interface{}{
+			"ip_address": result.IP,
+			"alive":      result.Alive,
+			"reasons":    result.Reasons,
+		})
+	}
+
+	if err := d.Set("icmp_enabled", icmp); err != nil {
+		return err
+	}
+	if err := d.Set("live_hosts", live); err != nil {
+		return err
+	}
+	return d.Set("hosts", hosts)
+}
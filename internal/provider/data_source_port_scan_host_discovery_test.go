@@ -0,0 +1,47 @@
+package provider
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+func Test_dataSourcePortScanHostDiscoveryRead_id(t *testing.T) {
+	read := func(targets ...interface{}) string {
+		d := schema.TestResourceDataRaw(t, dataSourcePortScanHostDiscovery().Schema, map[string]interface{}{
+			"targets":          targets,
+			"ports":            []interface{}{1},
+			"icmp":             false,
+			"timeout_per_port": "100ms",
+		})
+		if err := dataSourcePortScanHostDiscoveryRead(d, &providerConfig{}); err != nil {
+			t.Fatal(err)
+		}
+		return d.Id()
+	}
+
+	id := read("127.0.0.1", "127.0.0.2")
+	if id != scanID([]string{"127.0.0.1", "127.0.0.2"}, "tcp", []int{1}, dialerDirect) {
+		t.Errorf("Expected the ID to be derived from the targets and ports, got %q", id)
+	}
+	if other := read("127.0.0.2", "127.0.0.1"); other != id {
+		t.Errorf("Expected the ID to not depend on the order of the targets, got %q and %q", id, other)
+	}
+	if other := read("127.0.0.1"); other == id {
+		t.Errorf("Expected a different sweep to have a different ID than %q", id)
+	}
+}
+
+func Test_dataSourcePortScanHostDiscoveryRead_maxScanDuration(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, dataSourcePortScanHostDiscovery().Schema, map[string]interface{}{
+		"targets":           []interface{}{"127.0.0.0/24"},
+		"icmp":              false,
+		"max_scan_duration": "1ns",
+	})
+
+	err := dataSourcePortScanHostDiscoveryRead(d, &providerConfig{})
+	if err == nil || !strings.Contains(err.Error(), "didn't complete within 1ns") {
+		t.Fatalf("Expected the sweep to stop at max_scan_duration, got %v", err)
+	}
+}
@@ -0,0 +1,288 @@
+package provider
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
+	scanner "github.com/picatz/terraform-provider-port-scan/internal/provider/port-scanner"
+)
+
+// dataSourcePortScanHosts scans the same ports on many hosts in a single
+// pipeline. Maps of objects aren't supported by the plugin SDK, so the
+// per-host results are a list, which can be turned into a map for for_each
+// using the ip_address of each host as the key.
+func dataSourcePortScanHosts() *schema.Resource {
+	return &schema.Resource{
+		Read:     dataSourcePortScanHostsRead,
+		Timeouts: scanTimeouts(),
+		Schema: map[string]*schema.Schema{
+			"targets": {
+				ForceNew:    true,
+				Required:    true,
+				Type:        schema.TypeList,
+				MinItems:    1,
+				Description: "IP addresses, hostnames or CIDR blocks to scan",
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validateTarget,
+				},
+			},
+			"port": {
+				ForceNew:      true,
+				Optional:      true,
+				Type:          schema.TypeInt,
+				ValidateFunc:  validation.IsPortNumber,
+				ConflictsWith: []string{"ports", "from_port", "to_port"},
+			},
+			"ports": {
+				ForceNew:      true,
+				Optional:      true,
+				Type:          schema.TypeList,
+				MinItems:      1,
+				ConflictsWith: []string{"port", "from_port", "to_port"},
+				Elem: &schema.Schema{
+					Type:         schema.TypeInt,
+					ValidateFunc: validation.IsPortNumber,
+				},
+			},
+			"from_port": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeInt,
+				Default:      1,
+				ValidateFunc: validation.IsPortNumber,
+			},
+			"to_port": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeInt,
+				Default:      1024,
+				ValidateFunc: validation.IsPortNumber,
+			},
+			// Optional timeout controls
+			"timeout_per_port": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeString,
+				Default:      scanner.DefaultTimeoutPerPort.String(),
+				ValidateFunc: validateDuration,
+				Description:  "Dial timeout for each port, or the upper bound for adaptive timeouts",
+			},
+			"timeout_mode": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeString,
+				Default:      string(scanner.TimeoutModeFixed),
+				ValidateFunc: validation.StringInSlice([]string{string(scanner.TimeoutModeFixed), string(scanner.TimeoutModeAdaptive)}, false),
+				Description:  "Either \"fixed\" to always use timeout_per_port, or \"adaptive\" to derive timeouts from measured round trip times",
+			},
+			// Optional retry controls
+			"retry_attempts": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeInt,
+				Default:      1,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "Total number of attempts for ports that time out, including the first one",
+			},
+			"retry_backoff": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeString,
+				Default:      "500ms",
+				ValidateFunc: validateDuration,
+				Description:  "Delay before the first retry, doubled for every retry after it",
+			},
+			"confirmations": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeInt,
+				Default:      1,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "Number of consecutive consistent results required before the state of a port is reported",
+			},
+			// Optional scan politeness controls
+			"rate_limit":      rateLimitSchema(),
+			"host_rate_limit": hostRateLimitSchema(),
+			"jitter":          jitterSchema(),
+			"randomize_ports": randomizePortsSchema(),
+			// Optional scan deadline
+			"max_scan_duration": maxScanDurationSchema(),
+			"timeouts":          timeoutsSchema(),
+			// Optional report of the scan
+			"report_file":   reportFileSchema(),
+			"report_format": reportFormatSchema(),
+			// Optional SSH Bastion
+			"ssh_bastion": sshBastionSchema(),
+			// Computed fields
+			"ip_addresses": {
+				Computed:    true,
+				Type:        schema.TypeList,
+				Description: "Addresses the targets expanded to, in the order they were given",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"ingress_rules": ingressRulesSchema(),
+			"hosts": {
+				Computed:    true,
+				Type:        schema.TypeList,
+				Description: "Scan results for each address",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"ip_address": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"open_ports": {
+							Type:     schema.TypeList,
+							Computed: true,
+							Elem: &schema.Schema{
+								Type: schema.TypeInt,
+							},
+						},
+						"closed_ports": {
+							Type:     schema.TypeList,
+							Computed: true,
+							Elem: &schema.Schema{
+								Type: schema.TypeInt,
+							},
+						},
+						"filtered_ports": {
+							Type:     schema.TypeList,
+							Computed: true,
+							Elem: &schema.Schema{
+								Type: schema.TypeInt,
+							},
+						},
+						"errors": {
+							Type:        schema.TypeMap,
+							Computed:    true,
+							Description: "Error for each filtered port that didn't simply time out",
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+						"latency_ms": {
+							Type:     schema.TypeMap,
+							Computed: true,
+							Elem: &schema.Schema{
+								Type: schema.TypeFloat,
+							},
+						},
+						"latency_avg_ms": {
+							Type:     schema.TypeFloat,
+							Computed: true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourcePortScanHostsRead(d *schema.ResourceData, meta interface{}) error {
+	targets := []string{}
+	for _, target := range d.Get("targets").([]interface{}) {
+		if target != nil {
+			targets = append(targets, target.(string))
+		}
+	}
+
+	if err := validatePortRange(d); err != nil {
+		return err
+	}
+
+	ipAddresses, err := scanner.ExpandTargets(targets)
+	if err != nil {
+		return err
+	}
+
+	ports := portsToScan(d)
+
+	if err := meta.(*providerConfig).validateProbes(len(ipAddresses), len(ports)); err != nil {
+		return err
+	}
+
+	// the ID doesn't depend on the order of the targets either
+	sortedTargets := append([]string{}, targets...)
+	sort.Strings(sortedTargets)
+
+	d.SetId(scanID(sortedTargets, "tcp", ports, dialerIdentity(d)))
+
+	start := time.Now()
+
+	results, err := boundedScan(d, meta, ipAddresses, ports)
+	if err != nil {
+		return err
+	}
+
+	if err := writeReportFile(d, start, time.Since(start), results); err != nil {
+		return err
+	}
+
+	if err := d.Set("ip_addresses", ipAddresses); err != nil {
+		return err
+	}
+
+	// a single set of rules allows the ports open on any of the hosts
+	if err := d.Set("ingress_rules", ingressRuleObjects(openPorts(results))); err != nil {
+		return err
+	}
+
+	return d.Set("hosts", hostResults(ipAddresses, results))
+}
+
+// hostResults groups the sorted scan results by host, keeping the order of
+// the given IP addresses.
+func hostResults(ipAddresses []string, results []scanner.PortScanResult) []interface{} {
+	byHost := map[string][]scanner.PortScanResult{}
+	for _, result := range results {
+		byHost[result.IP] = append(byHost[result.IP], result)
+	}
+
+	hosts := []interface{}{}
+	for _, ip := range ipAddresses {
+		var (
+			open        = []int{}
+			closed      = []int{}
+			filtered    = []int{}
+			errors      = map[string]interface{}{}
+			latencies   = []time.Duration{}
+			latenciesMs = map[string]interface{}{}
+		)
+
+		for _, result := range byHost[ip] {
+			switch result.State {
+			case scanner.PortStateOpen:
+				open = append(open, result.Port)
+				latencies = append(latencies, result.Latency)
+				latenciesMs[strconv.Itoa(result.Port)] = milliseconds(result.Latency)
+			case scanner.PortStateClosed:
+				closed = append(closed, result.Port)
+			default:
+				filtered = append(filtered, result.Port)
+				if result.Error != nil && !result.TimedOut() {
+					errors[strconv.Itoa(result.Port)] = result.Error.Error()
+				}
+			}
+		}
+
+		_, avg, _ := latencyStats(latencies)
+
+		hosts = append(hosts, map[string]interface{}{
+			"ip_address":     ip,
+			"open_ports":     open,
+			"closed_ports":   closed,
+			"filtered_ports": filtered,
+			"errors":         errors,
+			"latency_ms":     latenciesMs,
+			"latency_avg_ms": avg,
+		})
+	}
+
+	return hosts
+}
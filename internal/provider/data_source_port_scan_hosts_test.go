@@ -0,0 +1,96 @@
+package provider
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+	scanner "github.com/picatz/terraform-provider-port-scan/internal/provider/port-scanner"
+)
+
+func Test_hostResults(t *testing.T) {
+	results := []scanner.PortScanResult{
+		{IP: "10.0.0.1", Port: 22, Open: true, State: scanner.PortStateOpen, Latency: 2 * time.Millisecond},
+		{IP: "10.0.0.1", Port: 80, State: scanner.PortStateClosed},
+		{IP: "10.0.0.1", Port: 443, State: scanner.PortStateFiltered, Error: context.DeadlineExceeded},
+		{IP: "10.0.0.2", Port: 22, State: scanner.PortStateFiltered, Error: context.Canceled},
+	}
+
+	hosts := hostResults([]string{"10.0.0.2", "10.0.0.1", "10.0.0.3"}, results)
+
+	if len(hosts) != 3 {
+		t.Fatalf("Expected 3 hosts, got %d", len(hosts))
+	}
+
+	first := hosts[0].(map[string]interface{})
+	if first["ip_address"] != "10.0.0.2" {
+		t.Errorf("Expected hosts in the given order, got %v first", first["ip_address"])
+	}
+	if errors := first["errors"].(map[string]interface{}); errors["22"] != context.Canceled.Error() {
+		t.Errorf("Expected an error for port 22, got %v", errors)
+	}
+
+	second := hosts[1].(map[string]interface{})
+	if open := second["open_ports"].([]int); !reflect.DeepEqual(open, []int{22}) {
+		t.Errorf("Expected open ports [22], got %v", open)
+	}
+	if closed := second["closed_ports"].([]int); !reflect.DeepEqual(closed, []int{80}) {
+		t.Errorf("Expected closed ports [80], got %v", closed)
+	}
+	if filtered := second["filtered_ports"].([]int); !reflect.DeepEqual(filtered, []int{443}) {
+		t.Errorf("Expected filtered ports [443], got %v", filtered)
+	}
+	if errors := second["errors"].(map[string]interface{}); len(errors) != 0 {
+		t.Errorf("Expected timeouts to not be reported as errors, got %v", errors)
+	}
+	if avg := second["latency_avg_ms"].(float64); avg != 2 {
+		t.Errorf("Expected average latency of 2ms, got %v", avg)
+	}
+
+	third := hosts[2].(map[string]interface{})
+	if open := third["open_ports"].([]int); len(open) != 0 {
+		t.Errorf("Expected no open ports for a host without results, got %v", open)
+	}
+}
+
+func Test_dataSourcePortScanHostsRead_id(t *testing.T) {
+	read := func(targets ...interface{}) string {
+		d := schema.TestResourceDataRaw(t, dataSourcePortScanHosts().Schema, map[string]interface{}{
+			"targets":    targets,
+			"port":       1,
+			"rate_limit": 100.0,
+			"jitter":     "1ms",
+		})
+		if err := dataSourcePortScanHostsRead(d, &providerConfig{}); err != nil {
+			t.Fatal(err)
+		}
+		return d.Id()
+	}
+
+	id := read("127.0.0.1", "127.0.0.2")
+	if id != scanID([]string{"127.0.0.1", "127.0.0.2"}, "tcp", []int{1}, dialerDirect) {
+		t.Errorf("Expected the ID to be derived from the targets and ports, got %q", id)
+	}
+	if other := read("127.0.0.2", "127.0.0.1"); other != id {
+		t.Errorf("Expected the ID to not depend on the order of the targets, got %q and %q", id, other)
+	}
+	if other := read("127.0.0.1"); other == id {
+		t.Errorf("Expected a different scan to have a different ID than %q", id)
+	}
+}
+
+func Test_dataSourcePortScanHosts_validate(t *testing.T) {
+	r := dataSourcePortScanHosts()
+	for _, key := range []string{"rate_limit", "host_rate_limit"} {
+		_, errs := r.Validate(terraform.NewResourceConfigRaw(map[string]interface{}{
+			"targets": []interface{}{"127.0.0.1"},
+			key:       -1.0,
+		}))
+		if len(errs) == 0 {
+			t.Errorf("Expected a negative %s to be invalid", key)
+		}
+	}
+}
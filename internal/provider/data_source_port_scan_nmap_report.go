@@ -0,0 +1,161 @@
+package provider
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	scanner "github.com/picatz/terraform-provider-port-scan/internal/provider/port-scanner"
+)
+
+// dataSourcePortScanNmapReport reads the results of an existing nmap scan
+// from its XML report, in the same shape as the port_scan data source.
+func dataSourcePortScanNmapReport() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourcePortScanNmapReportRead,
+		Schema: map[string]*schema.Schema{
+			"file": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeString,
+				ExactlyOneOf: []string{"file", "content"},
+				Description:  "Path of the nmap XML report",
+			},
+			"content": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeString,
+				ExactlyOneOf: []string{"file", "content"},
+				Description:  "Content of the nmap XML report",
+			},
+			// Computed fields
+			"scanned_at": {
+				Computed:    true,
+				Type:        schema.TypeString,
+				Description: "RFC 3339 timestamp of when the scan started",
+			},
+			"duration_ms": {
+				Computed:    true,
+				Type:        schema.TypeInt,
+				Description: "Time the scan took in milliseconds",
+			},
+			"ip_addresses": {
+				Computed:    true,
+				Type:        schema.TypeList,
+				Description: "Addresses of all of the hosts in the report",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"open_endpoints": {
+				Computed:    true,
+				Type:        schema.TypeList,
+				Description: "Open ports of all of the hosts as \"ip:port\" endpoints",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"hosts": {
+				Computed:    true,
+				Type:        schema.TypeList,
+				Description: "Results for each host, in the order of the report",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"ip_address": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"hostnames": {
+							Type:     schema.TypeList,
+							Computed: true,
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+						"up": {
+							Type:     schema.TypeBool,
+							Computed: true,
+						},
+						"open_ports": {
+							Type:     schema.TypeList,
+							Computed: true,
+							Elem: &schema.Schema{
+								Type: schema.TypeInt,
+							},
+						},
+						"open_endpoints": {
+							Type:     schema.TypeList,
+							Computed: true,
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+						"results": resultsSchema(),
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourcePortScanNmapReportRead(d *schema.ResourceData, meta interface{}) error {
+	var content []byte
+	if v, ok := d.GetOk("file"); ok {
+		data, err := ioutil.ReadFile(v.(string))
+		if err != nil {
+			return err
+		}
+		content = data
+	} else {
+		content = []byte(d.Get("content").(string))
+	}
+
+	report, err := scanner.ReadNmapXML(bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(content)
+	d.SetId(hex.EncodeToString(sum[:]))
+
+	byHost := map[string][]scanner.PortScanResult{}
+	for _, result := range report.Results {
+		byHost[result.IP] = append(byHost[result.IP], result)
+	}
+
+	var (
+		ipAddresses = []string{}
+		hosts       = []interface{}{}
+	)
+
+	for _, host := range report.Hosts {
+		ipAddresses = append(ipAddresses, host.IP)
+
+		results := byHost[host.IP]
+		hosts = append(hosts, map[string]interface{}{
+			"ip_address":     host.IP,
+			"hostnames":      host.Hostnames,
+			"up":             host.Up,
+			"open_ports":     openPorts(results),
+			"open_endpoints": openEndpoints(results),
+			"results":        resultObjects(results),
+		})
+	}
+
+	if err := d.Set("scanned_at", report.StartedAt.Format(time.RFC3339)); err != nil {
+		return err
+	}
+	if err := d.Set("duration_ms", int(report.Duration/time.Millisecond)); err != nil {
+		return err
+	}
+	if err := d.Set("ip_addresses", ipAddresses); err != nil {
+		return err
+	}
+	if err := d.Set("open_endpoints", openEndpoints(report.Results)); err != nil {
+		return err
+	}
+	return d.Set("hosts", hosts)
+}
@@ -0,0 +1,64 @@
+package provider
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	scanner "github.com/picatz/terraform-provider-port-scan/internal/provider/port-scanner"
+)
+
+func Test_dataSourcePortScanNmapReportRead(t *testing.T) {
+	buf := &bytes.Buffer{}
+	err := scanner.WriteNmapXML(buf, &scanner.Report{
+		StartedAt: time.Date(2020, 7, 1, 12, 0, 0, 0, time.UTC),
+		Duration:  2 * time.Second,
+		Results: []scanner.PortScanResult{
+			{IP: "10.0.0.1", Port: 22, Open: true, State: scanner.PortStateOpen},
+			{IP: "10.0.0.1", Port: 80, State: scanner.PortStateClosed},
+			{IP: "10.0.0.2", Port: 22, State: scanner.PortStateFiltered},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := schema.TestResourceDataRaw(t, dataSourcePortScanNmapReport().Schema, map[string]interface{}{
+		"content": buf.String(),
+	})
+	if err := dataSourcePortScanNmapReportRead(d, &providerConfig{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if scannedAt := d.Get("scanned_at").(string); scannedAt != "2020-07-01T12:00:00Z" {
+		t.Errorf("Expected the start of the scan, got %q", scannedAt)
+	}
+	if duration := d.Get("duration_ms").(int); duration != 2000 {
+		t.Errorf("Expected a duration of 2000ms, got %d", duration)
+	}
+	if endpoints := d.Get("open_endpoints").([]interface{}); !reflect.DeepEqual(endpoints, []interface{}{"10.0.0.1:22"}) {
+		t.Errorf("Expected the open endpoint of the report, got %v", endpoints)
+	}
+
+	if n := d.Get("hosts.#").(int); n != 2 {
+		t.Fatalf("Expected 2 hosts, got %d", n)
+	}
+	if !d.Get("hosts.0.up").(bool) || d.Get("hosts.1.up").(bool) {
+		t.Errorf("Expected only the first host to be up")
+	}
+	if state := d.Get("hosts.0.results.1.state").(string); state != "closed" {
+		t.Errorf("Expected port 80 to be closed, got %q", state)
+	}
+	if service := d.Get("hosts.0.results.0.service").(string); service != "ssh" {
+		t.Errorf("Expected the ssh service, got %q", service)
+	}
+
+	d = schema.TestResourceDataRaw(t, dataSourcePortScanNmapReport().Schema, map[string]interface{}{
+		"content": "not xml",
+	})
+	if err := dataSourcePortScanNmapReportRead(d, &providerConfig{}); err == nil {
+		t.Error("Expected an error for an invalid report")
+	}
+}
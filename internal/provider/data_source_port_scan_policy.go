@@ -0,0 +1,410 @@
+package provider
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
+	scanner "github.com/picatz/terraform-provider-port-scan/internal/provider/port-scanner"
+)
+
+// dataSourcePortScanPolicy scans hosts and evaluates port exposure rules
+// declared in HCL against the results, reporting the violations of each rule.
+func dataSourcePortScanPolicy() *schema.Resource {
+	portList := func(description string) *schema.Schema {
+		return &schema.Schema{
+			Optional:    true,
+			Type:        schema.TypeList,
+			Description: description,
+			Elem: &schema.Schema{
+				Type:         schema.TypeInt,
+				ValidateFunc: validation.IsPortNumber,
+			},
+		}
+	}
+
+	return &schema.Resource{
+		Read:     dataSourcePortScanPolicyRead,
+		Timeouts: scanTimeouts(),
+		Schema: map[string]*schema.Schema{
+			"host": {
+				ForceNew:    true,
+				Required:    true,
+				Type:        schema.TypeList,
+				MinItems:    1,
+				Description: "Hosts to scan, with the tags rules can select them by",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"address": {
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validateTarget,
+							Description:  "IP address, hostname or CIDR block",
+						},
+						"tags": {
+							Type:        schema.TypeList,
+							Optional:    true,
+							Description: "Tags of every address of the host",
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+					},
+				},
+			},
+			"rule": {
+				ForceNew:    true,
+				Required:    true,
+				Type:        schema.TypeList,
+				MinItems:    1,
+				Description: "Port exposure rules the hosts are checked against",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"cidrs": {
+							Type:        schema.TypeList,
+							Optional:    true,
+							Description: "Only apply the rule to addresses in these CIDR blocks",
+							Elem: &schema.Schema{
+								Type:         schema.TypeString,
+								ValidateFunc: validation.IsCIDR,
+							},
+						},
+						"tags": {
+							Type:        schema.TypeList,
+							Optional:    true,
+							Description: "Only apply the rule to hosts with any of these tags",
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+						"allowed_ports":   portList("Ports allowed to be open, any other open port is a violation when set"),
+						"forbidden_ports": portList("Ports never allowed to be open"),
+						"required_ports":  portList("Ports that must be open"),
+						// Computed fields
+						"passed": {
+							Type:     schema.TypeBool,
+							Computed: true,
+						},
+						"ip_addresses": {
+							Type:        schema.TypeList,
+							Computed:    true,
+							Description: "Addresses the rule applies to",
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+						"violations": {
+							Type:     schema.TypeList,
+							Computed: true,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"ip_address": {
+										Type:     schema.TypeString,
+										Computed: true,
+									},
+									"port": {
+										Type:     schema.TypeInt,
+										Computed: true,
+									},
+									"type": {
+										Type:     schema.TypeString,
+										Computed: true,
+									},
+									"message": {
+										Type:     schema.TypeString,
+										Computed: true,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			"fail_on_violation": {
+				ForceNew:    true,
+				Optional:    true,
+				Type:        schema.TypeBool,
+				Default:     false,
+				Description: "Return an error listing the violations when any rule fails",
+			},
+			"port": {
+				ForceNew:      true,
+				Optional:      true,
+				Type:          schema.TypeInt,
+				ValidateFunc:  validation.IsPortNumber,
+				ConflictsWith: []string{"ports", "from_port", "to_port"},
+			},
+			"ports": {
+				ForceNew:      true,
+				Optional:      true,
+				Type:          schema.TypeList,
+				MinItems:      1,
+				ConflictsWith: []string{"port", "from_port", "to_port"},
+				Elem: &schema.Schema{
+					Type:         schema.TypeInt,
+					ValidateFunc: validation.IsPortNumber,
+				},
+			},
+			"from_port": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeInt,
+				Default:      1,
+				ValidateFunc: validation.IsPortNumber,
+			},
+			"to_port": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeInt,
+				Default:      1024,
+				ValidateFunc: validation.IsPortNumber,
+			},
+			// Optional timeout controls
+			"timeout_per_port": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeString,
+				Default:      scanner.DefaultTimeoutPerPort.String(),
+				ValidateFunc: validateDuration,
+				Description:  "Dial timeout for each port, or the upper bound for adaptive timeouts",
+			},
+			"timeout_mode": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeString,
+				Default:      string(scanner.TimeoutModeFixed),
+				ValidateFunc: validation.StringInSlice([]string{string(scanner.TimeoutModeFixed), string(scanner.TimeoutModeAdaptive)}, false),
+				Description:  "Either \"fixed\" to always use timeout_per_port, or \"adaptive\" to derive timeouts from measured round trip times",
+			},
+			// Optional retry controls
+			"retry_attempts": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeInt,
+				Default:      1,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "Total number of attempts for ports that time out, including the first one",
+			},
+			"retry_backoff": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeString,
+				Default:      "500ms",
+				ValidateFunc: validateDuration,
+				Description:  "Delay before the first retry, doubled for every retry after it",
+			},
+			"confirmations": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeInt,
+				Default:      1,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "Number of consecutive consistent results required before the state of a port is reported",
+			},
+			// Optional scan deadline
+			"max_scan_duration": maxScanDurationSchema(),
+			"timeouts":          timeoutsSchema(),
+			// Optional report of the scan
+			"report_file":   reportFileSchema(),
+			"report_format": reportFormatSchema(),
+			// Optional SSH Bastion
+			"ssh_bastion": sshBastionSchema(),
+			// Computed fields
+			"passed": {
+				Computed:    true,
+				Type:        schema.TypeBool,
+				Description: "Whether every rule passed",
+			},
+			"ip_addresses": {
+				Computed:    true,
+				Type:        schema.TypeList,
+				Description: "Addresses the hosts expanded to, in the order they were given",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"violations": {
+				Computed:    true,
+				Type:        schema.TypeList,
+				Description: "Messages describing the violations of every rule",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+		},
+	}
+}
+
+// policyHosts expands the addresses of the host blocks, merging the tags of
+// addresses listed by more than one of them.
+func policyHosts(d *schema.ResourceData) ([]*policyHost, error) {
+	var (
+		hosts  = []*policyHost{}
+		byAddr = map[string]*policyHost{}
+	)
+
+	for _, v := range d.Get("host").([]interface{}) {
+		block := v.(map[string]interface{})
+
+		ipAddresses, err := scanner.ExpandTargets([]string{block["address"].(string)})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ip := range ipAddresses {
+			host, ok := byAddr[ip]
+			if !ok {
+				host = &policyHost{ip: ip, tags: map[string]bool{}}
+				byAddr[ip] = host
+				hosts = append(hosts, host)
+			}
+			for _, tag := range block["tags"].([]interface{}) {
+				if tag != nil {
+					host.tags[tag.(string)] = true
+				}
+			}
+		}
+	}
+
+	if len(hosts) > scanner.MaxTargets {
+		return nil, fmt.Errorf("hosts expand to %d addresses, more than the maximum of %d", len(hosts), scanner.MaxTargets)
+	}
+
+	return hosts, nil
+}
+
+// policyRules reads the rule blocks.
+func policyRules(d *schema.ResourceData) ([]*policyRule, error) {
+	rules := []*policyRule{}
+
+	for _, v := range d.Get("rule").([]interface{}) {
+		block := v.(map[string]interface{})
+
+		rule := &policyRule{
+			name:      block["name"].(string),
+			tags:      []string{},
+			allowed:   uniquePorts(convertIntArr(block["allowed_ports"].([]interface{}))),
+			forbidden: uniquePorts(convertIntArr(block["forbidden_ports"].([]interface{}))),
+			required:  uniquePorts(convertIntArr(block["required_ports"].([]interface{}))),
+		}
+
+		for _, cidr := range block["cidrs"].([]interface{}) {
+			if cidr == nil {
+				continue
+			}
+			_, ipNet, err := net.ParseCIDR(cidr.(string))
+			if err != nil {
+				return nil, fmt.Errorf("rule %q: %s", rule.name, err)
+			}
+			rule.cidrs = append(rule.cidrs, ipNet)
+		}
+
+		for _, tag := range block["tags"].([]interface{}) {
+			if tag != nil {
+				rule.tags = append(rule.tags, tag.(string))
+			}
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+func dataSourcePortScanPolicyRead(d *schema.ResourceData, meta interface{}) error {
+	if err := validatePortRange(d); err != nil {
+		return err
+	}
+
+	hosts, err := policyHosts(d)
+	if err != nil {
+		return err
+	}
+
+	rules, err := policyRules(d)
+	if err != nil {
+		return err
+	}
+
+	ipAddresses := []string{}
+	for _, host := range hosts {
+		ipAddresses = append(ipAddresses, host.ip)
+	}
+
+	// forbidden and required ports are always scanned, so they can be checked
+	ports := portsToScan(d)
+	for _, rule := range rules {
+		ports = uniquePorts(ports, rule.forbidden, rule.required)
+	}
+
+	if err := meta.(*providerConfig).validateProbes(len(ipAddresses), len(ports)); err != nil {
+		return err
+	}
+
+	d.SetId(scanID(ipAddresses, "tcp", ports, dialerIdentity(d)))
+
+	start := time.Now()
+
+	results, err := boundedScan(d, meta, ipAddresses, ports)
+	if err != nil {
+		return err
+	}
+
+	if err := writeReportFile(d, start, time.Since(start), results); err != nil {
+		return err
+	}
+
+	var (
+		passed     = true
+		ruleBlocks = d.Get("rule").([]interface{})
+		messages   = []string{}
+	)
+
+	for i, rule := range rules {
+		result := rule.evaluate(hosts, results)
+
+		violations := []interface{}{}
+		for _, violation := range result.violations {
+			violations = append(violations, map[string]interface{}{
+				"ip_address": violation.ip,
+				"port":       violation.port,
+				"type":       violation.kind,
+				"message":    violation.message,
+			})
+			messages = append(messages, violation.message)
+		}
+
+		block := ruleBlocks[i].(map[string]interface{})
+		block["passed"] = result.passed()
+		block["ip_addresses"] = result.hosts
+		block["violations"] = violations
+
+		passed = passed && result.passed()
+	}
+
+	if err := d.Set("rule", ruleBlocks); err != nil {
+		return err
+	}
+	if err := d.Set("ip_addresses", ipAddresses); err != nil {
+		return err
+	}
+	if err := d.Set("passed", passed); err != nil {
+		return err
+	}
+	if err := d.Set("violations", messages); err != nil {
+		return err
+	}
+
+	if !passed && d.Get("fail_on_violation").(bool) {
+		return fmt.Errorf("port exposure policy violated: %s", strings.Join(messages, "; "))
+	}
+
+	return nil
+}
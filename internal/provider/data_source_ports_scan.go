@@ -1,133 +1,431 @@
 package provider
 
 import (
-	"bufio"
-	"bytes"
-	"crypto/subtle"
-	"encoding/base64"
+	"context"
 	"fmt"
-	"net"
-	"strings"
 	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
 	scanner "github.com/picatz/terraform-provider-port-scan/internal/provider/port-scanner"
-	"golang.org/x/crypto/ssh"
 )
 
 func dataSourcePortScan() *schema.Resource {
 	return &schema.Resource{
-		Read: dataSourcePortScanRead,
+		Read:     dataSourcePortScanRead,
+		Timeouts: scanTimeouts(),
 		Schema: map[string]*schema.Schema{
 			"ip_address": {
-				ForceNew: true,
-				Required: true,
-				Type:     schema.TypeString,
+				ForceNew:     true,
+				Required:     true,
+				Type:         schema.TypeString,
+				ValidateFunc: validateHost,
 			},
 			"port": {
-				ForceNew: true,
-				Optional: true,
-				Type:     schema.TypeInt,
+				ForceNew:      true,
+				Optional:      true,
+				Type:          schema.TypeInt,
+				ValidateFunc:  validation.IsPortNumber,
+				ConflictsWith: []string{"ports", "from_port", "to_port"},
 			},
 			"ports": {
-				ForceNew: true,
-				Optional: true,
-				Type:     schema.TypeList,
+				ForceNew:      true,
+				Optional:      true,
+				Type:          schema.TypeList,
+				MinItems:      1,
+				ConflictsWith: []string{"port", "from_port", "to_port"},
 				Elem: &schema.Schema{
-					Type: schema.TypeInt,
+					Type:         schema.TypeInt,
+					ValidateFunc: validation.IsPortNumber,
 				},
 			},
 			"from_port": {
-				ForceNew: true,
-				Optional: true,
-				Type:     schema.TypeInt,
-				Default:  1,
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeInt,
+				Default:      1,
+				ValidateFunc: validation.IsPortNumber,
 			},
 			"to_port": {
-				ForceNew: true,
-				Optional: true,
-				Type:     schema.TypeInt,
-				Default:  1024,
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeInt,
+				Default:      1024,
+				ValidateFunc: validation.IsPortNumber,
+			},
+			// Optional expected port states
+			"expected_open_ports": {
+				ForceNew:    true,
+				Optional:    true,
+				Type:        schema.TypeList,
+				Description: "Ports expected to be open, any other open port is unexpected",
+				Elem: &schema.Schema{
+					Type:         schema.TypeInt,
+					ValidateFunc: validation.IsPortNumber,
+				},
+			},
+			"expected_closed_ports": {
+				ForceNew:    true,
+				Optional:    true,
+				Type:        schema.TypeList,
+				Description: "Ports expected to not be open",
+				Elem: &schema.Schema{
+					Type:         schema.TypeInt,
+					ValidateFunc: validation.IsPortNumber,
+				},
+			},
+			"fail_on_mismatch": {
+				ForceNew:    true,
+				Optional:    true,
+				Type:        schema.TypeBool,
+				Default:     false,
+				Description: "Return an error listing the differences when the observed ports don't match the expected ports",
+			},
+			// Optional timeout controls
+			"timeout_per_port": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeString,
+				Default:      scanner.DefaultTimeoutPerPort.String(),
+				ValidateFunc: validateDuration,
+				Description:  "Dial timeout for each port, or the upper bound for adaptive timeouts",
+			},
+			"timeout_mode": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeString,
+				Default:      string(scanner.TimeoutModeFixed),
+				ValidateFunc: validation.StringInSlice([]string{string(scanner.TimeoutModeFixed), string(scanner.TimeoutModeAdaptive)}, false),
+				Description:  "Either \"fixed\" to always use timeout_per_port, or \"adaptive\" to derive timeouts from measured round trip times",
+			},
+			// Optional retry controls
+			"retry_attempts": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeInt,
+				Default:      1,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "Total number of attempts for ports that time out, including the first one",
+			},
+			"retry_backoff": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeString,
+				Default:      "500ms",
+				ValidateFunc: validateDuration,
+				Description:  "Delay before the first retry, doubled for every retry after it",
+			},
+			"confirmations": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeInt,
+				Default:      1,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "Number of consecutive consistent results required before the state of a port is reported",
+			},
+			// Optional scan politeness controls
+			"rate_limit":      rateLimitSchema(),
+			"host_rate_limit": hostRateLimitSchema(),
+			"jitter":          jitterSchema(),
+			"randomize_ports": randomizePortsSchema(),
+			// Optional service detection
+			"grab_banners": {
+				ForceNew:    true,
+				Optional:    true,
+				Type:        schema.TypeBool,
+				Default:     false,
+				Description: "Read the banner sent by the service on each open port",
+			},
+			"banner_timeout": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeString,
+				Default:      scanner.DefaultBannerTimeout.String(),
+				ValidateFunc: validateDuration,
+				Description:  "How long to wait for the banner on each open port",
+			},
+			"probers": {
+				ForceNew:    true,
+				Optional:    true,
+				Type:        schema.TypeList,
+				Description: "Probers run on the open ports they apply to, such as \"tls\" or \"http\"",
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validation.StringInSlice(scanner.DefaultRegistry.Names(), false),
+				},
 			},
+			"probe_timeout": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeString,
+				ValidateFunc: validateDuration,
+				Description:  "How long each prober may take on each open port, defaults to banner_timeout",
+			},
+			// Optional scan deadline
+			"max_scan_duration": maxScanDurationSchema(),
+			"timeouts":          timeoutsSchema(),
+			"partial_results": {
+				ForceNew:    true,
+				Optional:    true,
+				Type:        schema.TypeBool,
+				Default:     false,
+				Description: "Return the results scanned so far instead of an error when the scan doesn't complete in time",
+			},
+			// Optional caching, when enabled in the provider
+			"cache_ttl": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeString,
+				ValidateFunc: validateDuration,
+				Description:  "How long cached results are reused for, overriding the provider's cache_ttl",
+			},
+			"bypass_cache": {
+				ForceNew:    true,
+				Optional:    true,
+				Type:        schema.TypeBool,
+				Default:     false,
+				Description: "Always scan, instead of reusing cached results",
+			},
+			// Optional report of the scan
+			"report_file":   reportFileSchema(),
+			"report_format": reportFormatSchema(),
+			"sarif_file":    sarifFileSchema(),
 			// Optional SSH Bastion
-			"ssh_bastion": {
+			"ssh_bastion": sshBastionSchema(),
+			// Computed fields
+			"open_ports": {
+				Computed: true,
 				Type:     schema.TypeList,
-				Optional: true,
-				MaxItems: 1,
+				Elem: &schema.Schema{
+					Type: schema.TypeInt,
+				},
+			},
+			"ingress_rules": ingressRulesSchema(),
+			"open_endpoints": {
+				Computed:    true,
+				Type:        schema.TypeList,
+				Description: "Open ports as \"ip:port\" endpoints",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"results": resultsSchema(),
+			// Known-dangerous services found open
+			"suppression": suppressionSchema(),
+			"findings":    findingsSchema(),
+			"cached": {
+				Computed:    true,
+				Type:        schema.TypeBool,
+				Description: "Whether the results were reused from the cache",
+			},
+			"complete": {
+				Computed:    true,
+				Type:        schema.TypeBool,
+				Description: "Whether all of the ports were scanned before the deadline",
+			},
+			"scanned_at": {
+				Computed:    true,
+				Type:        schema.TypeString,
+				Description: "RFC 3339 timestamp of when the scan started",
+			},
+			"duration_ms": {
+				Computed:    true,
+				Type:        schema.TypeInt,
+				Description: "How long the scan took in milliseconds",
+			},
+			"probes_sent": {
+				Computed:    true,
+				Type:        schema.TypeInt,
+				Description: "Number of connection attempts, including retries and confirmations",
+			},
+			"dialer": {
+				Computed:    true,
+				Type:        schema.TypeString,
+				Description: "Either \"direct\" or \"ssh_bastion\"",
+			},
+			"unexpected_open_ports": {
+				Computed:    true,
+				Type:        schema.TypeList,
+				Description: "Open ports that were expected to be closed, or weren't expected to be open",
+				Elem: &schema.Schema{
+					Type: schema.TypeInt,
+				},
+			},
+			"missing_open_ports": {
+				Computed:    true,
+				Type:        schema.TypeList,
+				Description: "Ports that were expected to be open, but weren't",
+				Elem: &schema.Schema{
+					Type: schema.TypeInt,
+				},
+			},
+			"unscanned_expected_ports": {
+				Computed:    true,
+				Type:        schema.TypeList,
+				Description: "Expected open or closed ports that weren't scanned before the deadline, with partial_results",
+				Elem: &schema.Schema{
+					Type: schema.TypeInt,
+				},
+			},
+			"latency_ms": {
+				Computed:    true,
+				Type:        schema.TypeMap,
+				Description: "Connect latency in milliseconds for each open port",
+				Elem: &schema.Schema{
+					Type: schema.TypeFloat,
+				},
+			},
+			"latency_min_ms": {
+				Computed:    true,
+				Type:        schema.TypeFloat,
+				Description: "Minimum connect latency in milliseconds across the open ports",
+			},
+			"latency_avg_ms": {
+				Computed:    true,
+				Type:        schema.TypeFloat,
+				Description: "Average connect latency in milliseconds across the open ports",
+			},
+			"latency_p95_ms": {
+				Computed:    true,
+				Type:        schema.TypeFloat,
+				Description: "95th percentile connect latency in milliseconds across the open ports",
+			},
+			"tcp_info": {
+				Computed:    true,
+				Type:        schema.TypeList,
+				Description: "Kernel TCP_INFO metrics for each open port, only available for direct scans on linux",
 				Elem: &schema.Resource{
 					Schema: map[string]*schema.Schema{
-						"ip_address": {
-							Type:        schema.TypeString,
-							Required:    true,
-							Description: "SSH bastion IP address",
-						},
 						"port": {
-							Type:        schema.TypeInt,
-							Default:     22,
-							Optional:    true,
-							Description: "SSH port",
-						},
-						"user": {
-							Type:        schema.TypeString,
-							Default:     "root",
-							Optional:    true,
-							Description: "SSH username",
-						},
-						"password": {
-							Type:        schema.TypeString,
-							Sensitive:   true,
-							Optional:    true,
-							Description: "SSH password",
+							Type:     schema.TypeInt,
+							Computed: true,
 						},
-						"private_key": {
-							Type:        schema.TypeString,
-							Sensitive:   true,
-							Optional:    true,
-							Description: "PEM encoded SSH private key",
+						"rtt_ms": {
+							Type:     schema.TypeFloat,
+							Computed: true,
 						},
-						"host_key": {
-							Type:        schema.TypeString,
-							Sensitive:   true,
-							Optional:    true,
-							Description: "Base64 encoded SSH bastion host key",
+						"rtt_var_ms": {
+							Type:     schema.TypeFloat,
+							Computed: true,
 						},
-						"insecure_ignore_host_key": {
-							Type:        schema.TypeBool,
-							Sensitive:   true,
-							Optional:    true,
-							Description: "Skip SSH bastion host key checkinng",
+						"retransmits": {
+							Type:     schema.TypeInt,
+							Computed: true,
 						},
 					},
 				},
 			},
-			// Computed fields
-			"open_ports": {
-				Computed: true,
-				Type:     schema.TypeList,
-				Elem: &schema.Schema{
-					Type: schema.TypeInt,
-				},
-			},
 		},
 	}
 }
 
 func dataSourcePortScanRead(d *schema.ResourceData, meta interface{}) error {
+	// First, grab the require IP address
+	ipAddress := d.Get("ip_address").(string)
+
+	// data sources don't support CustomizeDiff, so the port range is validated here
+	if err := validatePortRange(d); err != nil {
+		return err
+	}
+
+	ports := portsToScan(d)
+
+	// expected ports are always scanned, so they can be compared
+	expectedOpen, expectedClosed := expectedPorts(d)
+	if err := validateExpectedPorts(expectedOpen, expectedClosed); err != nil {
+		return err
+	}
+	ports = uniquePorts(ports, expectedOpen, expectedClosed)
+
+	if err := meta.(*providerConfig).validateProbes(1, len(ports)); err != nil {
+		return err
+	}
+
 	// Note: this took me FOREVER to figure out I needed to set an ID...
 	//       so everything would seemingly almost work, but the attributes
 	//       would never get set!
-	d.SetId("-")
+	d.SetId(scanID([]string{ipAddress}, "tcp", ports, dialerIdentity(d)))
+
+	config := meta.(*providerConfig)
+
+	scan, cached := config.cachedScan(d)
+	if !cached {
+		ctx, cancel, deadline := scanContext(d)
+		defer cancel()
+
+		start := time.Now()
+
+		results, err := runScan(ctx, d, meta, []string{ipAddress}, ports)
+		if err != nil {
+			return err
+		}
+
+		scan = &cacheEntry{ScannedAt: start, Duration: time.Since(start), Results: results}
+
+		// only complete scans are cached
+		if len(results) == len(ports) {
+			config.cacheScan(d, scan)
+		} else if !d.Get("partial_results").(bool) {
+			return fmt.Errorf("scan of %s didn't complete within %s, only %d of the %d ports were scanned, set partial_results to use them", ipAddress, deadline, len(results), len(ports))
+		}
+	}
+
+	results := scan.Results
+
+	if err := d.Set("cached", cached); err != nil {
+		return err
+	}
+
+	if err := d.Set("complete", len(results) == len(ports)); err != nil {
+		return err
+	}
+
+	if err := setScanMetadata(d, scan.ScannedAt, scan.Duration, results); err != nil {
+		return err
+	}
+
+	if err := writeReportFile(d, scan.ScannedAt, scan.Duration, results); err != nil {
+		return err
+	}
+
+	if err := writeSARIFFile(d, results); err != nil {
+		return err
+	}
+
+	open := openPorts(results)
+
+	if err := d.Set("open_ports", open); err != nil {
+		return err
+	}
+
+	if err := d.Set("ingress_rules", ingressRuleObjects(open)); err != nil {
+		return err
+	}
 
+	if err := setResults(d, results); err != nil {
+		return err
+	}
+
+	if err := setFindings(d, results); err != nil {
+		return err
+	}
+
+	if err := setLatencies(d, results); err != nil {
+		return err
+	}
+
+	return setExpectations(d, results)
+}
+
+// portsToScan returns the ports configured with either port, ports, or the
+// from_port and to_port range.
+func portsToScan(d resourceGetter) []int {
 	var (
-		ipAddress string
-		fromPort  int
-		toPort    int
-		ports     []int
+		fromPort int
+		toPort   int
+		ports    []int
 	)
 
-	// First, grab the require IP address
-	ipAddress = d.Get("ip_address").(string)
-
 	// check port options, for single or range
 	port, ok := d.GetOk("port")
 	if ok {
@@ -145,98 +443,34 @@ func dataSourcePortScanRead(d *schema.ResourceData, meta interface{}) error {
 		}
 	}
 
-	// default dialer
-	var dialer scanner.Dialer = scanner.DefaultDialer
-	defer dialer.Close()
-
-	// check if using SSH bastion
-	if _, ok := d.GetOk("ssh_bastion"); ok {
-		var (
-			bastionConnectTimeout time.Duration = 2 * time.Minute
-			bastionUser           string        = d.Get("ssh_bastion.0.user").(string)
-			bastionAddress        string        = fmt.Sprintf(
-				"%s:%d",
-				d.Get("ssh_bastion.0.ip_address").(string),
-				d.Get("ssh_bastion.0.port").(int),
-			)
-			sshClientConfig *ssh.ClientConfig = &ssh.ClientConfig{
-				Timeout: bastionConnectTimeout,
-				User:    bastionUser,
-				Auth:    []ssh.AuthMethod{},
-			}
-		)
-
-		// check if known host key or insecure ignore host key
-		if v, ok := d.GetOk("ssh_bastion.0.host_key"); ok {
-			sshClientConfig.HostKeyCallback = hostKey(v.(string))
-		} else {
-			insecureHostKeyCheck := d.Get("ssh_bastion.0.insecure_ignore_host_key").(bool)
-			if insecureHostKeyCheck {
-				sshClientConfig.HostKeyCallback = ssh.InsecureIgnoreHostKey()
-			}
-		}
-
-		// if using ssh key
-		if _, ok := d.GetOk("ssh_bastion.0.private_key"); ok {
-			pemEncodedPrivateKey := d.Get("ssh_bastion.0.private_key").(string)
-			authMethod, err := sshKey(pemEncodedPrivateKey)
-			if err != nil {
-				return err
-			}
-			sshClientConfig.Auth = append(sshClientConfig.Auth, authMethod)
-		} else { // using password
-			if _, ok := d.GetOk("ssh_bastion.0.password"); ok {
-				sshClientConfig.Auth = append(sshClientConfig.Auth, ssh.Password(d.Get("ssh_bastion.0.password").(string)))
-			} else { // no idea what we're using
-				return fmt.Errorf("no SSH private_key or password provided")
-			}
-		}
-
-		sshDialer, err := scanner.NewSSHBastionScanner(bastionAddress, sshClientConfig)
-		if err != nil {
-			return err
-		}
-
-		dialer = sshDialer
+	if len(ports) == 0 {
+		ports = scanner.PortRange(fromPort, toPort)
 	}
 
-	openPorts := []int{}
-
-	if len(ports) > 0 {
-		for _, port := range ports {
-			for result := range scanner.Run(dialer, ipAddress, port, port, scanner.DefaultTimeoutPerPort) {
-				if result.Open {
-					openPorts = append(openPorts, result.Port)
-				}
-			}
-		}
-	} else {
-		for result := range scanner.Run(dialer, ipAddress, fromPort, toPort, scanner.DefaultTimeoutPerPort) {
-			if result.Open {
-				openPorts = append(openPorts, result.Port)
-			}
-		}
+	return ports
+}
 
+// runScan scans the ports on the given IP addresses using the dialer and scan
+// options configured for the data source or resource, returning the sorted
+// results. Once the context is done, only the ports scanned so far are returned.
+func runScan(ctx context.Context, d *schema.ResourceData, meta interface{}, ipAddresses []string, ports []int) ([]scanner.PortScanResult, error) {
+	dialer, err := newDialer(d)
+	if err != nil {
+		return nil, err
 	}
+	defer dialer.Close()
 
-	return d.Set("open_ports", openPorts)
-}
+	opts := meta.(*providerConfig).scanOptions(d)
 
-func sshKey(key string) (ssh.AuthMethod, error) {
-	var trimmedKey string
+	results := []scanner.PortScanResult{}
 
-	bufioScanner := bufio.NewScanner(bytes.NewReader([]byte(key)))
-	for bufioScanner.Scan() {
-		if len(bufioScanner.Bytes()) > 0 {
-			trimmedKey += strings.TrimSpace(bufioScanner.Text()) + "\n"
-		}
+	for result := range scanner.RunHostsContext(ctx, dialer, ipAddresses, ports, opts) {
+		results = append(results, result)
 	}
 
-	signer, err := ssh.ParsePrivateKey([]byte(trimmedKey))
-	if err != nil {
-		return nil, err
-	}
-	return ssh.PublicKeys(signer), nil
+	sortResults(results)
+
+	return results, nil
 }
 
 func convertIntArr(ifaceArr []interface{}) []int {
@@ -250,16 +484,18 @@ func convertIntArr(ifaceArr []interface{}) []int {
 	return arr
 }
 
-func hostKey(hostKeyBase64 string) ssh.HostKeyCallback {
-	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
-		// TODO(kent): add check hostname/remote
-		hostKeyBytes, err := base64.StdEncoding.DecodeString(hostKeyBase64)
-		if err != nil {
-			return err
-		}
-		if subtle.ConstantTimeCompare(key.Marshal(), hostKeyBytes) != 1 {
-			return fmt.Errorf("ssh: server host key failed to match")
+// uniquePorts combines the lists of ports, removing duplicates while keeping
+// the order in which they were first given.
+func uniquePorts(lists ...[]int) []int {
+	seen := map[int]bool{}
+	ports := []int{}
+	for _, list := range lists {
+		for _, port := range list {
+			if !seen[port] {
+				seen[port] = true
+				ports = append(ports, port)
+			}
 		}
-		return nil
 	}
+	return ports
 }
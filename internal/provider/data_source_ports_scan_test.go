@@ -2,10 +2,13 @@ package provider
 
 import (
 	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
 	r "github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/terraform"
 )
 
 const testDataSourceUnknownKeyError = `
@@ -58,3 +61,48 @@ func TestDataSource_compileLocalhost5959(t *testing.T) {
 		},
 	})
 }
+
+func Test_dataSourcePortScanRead_maxScanDuration(t *testing.T) {
+	config := map[string]interface{}{
+		"ip_address":        "127.0.0.1",
+		"to_port":           65535,
+		"max_scan_duration": "1ns",
+	}
+
+	d := schema.TestResourceDataRaw(t, dataSourcePortScan().Schema, config)
+	if err := dataSourcePortScanRead(d, &providerConfig{}); err == nil {
+		t.Fatal("Expected an error when the scan doesn't complete in time")
+	}
+
+	config["partial_results"] = true
+
+	d = schema.TestResourceDataRaw(t, dataSourcePortScan().Schema, config)
+	if err := dataSourcePortScanRead(d, &providerConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	if d.Get("complete").(bool) {
+		t.Error("Expected the scan to be flagged as incomplete")
+	}
+}
+
+func Test_dataSourcePortScanRead_readTimeout(t *testing.T) {
+	config := map[string]interface{}{
+		"ip_address": "127.0.0.1",
+		"to_port":    65535,
+		"timeouts": []interface{}{
+			map[string]interface{}{"read": "1ns"},
+		},
+	}
+
+	// the data source is read the way the SDK reads it, which doesn't decode
+	// the timeouts of data sources
+	ds := dataSourcePortScan()
+
+	diff, err := ds.Diff(nil, terraform.NewResourceConfigRaw(config), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ds.ReadDataApply(diff, &providerConfig{}); err == nil || !strings.Contains(err.Error(), "didn't complete within 1ns") {
+		t.Fatalf("Expected the read timeout to cut the scan off, got %v", err)
+	}
+}
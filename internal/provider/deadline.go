@@ -0,0 +1,94 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	scanner "github.com/picatz/terraform-provider-port-scan/internal/provider/port-scanner"
+)
+
+// defaultReadTimeout bounds every scan without a shorter read timeout or
+// max_scan_duration.
+const defaultReadTimeout = 20 * time.Minute
+
+// scanTimeouts are the timeouts of the scanning data sources. The SDK rejects
+// a timeouts block with keys that aren't declared here.
+func scanTimeouts() *schema.ResourceTimeout {
+	return &schema.ResourceTimeout{
+		Read: schema.DefaultTimeout(defaultReadTimeout),
+	}
+}
+
+// timeoutsSchema is the timeouts block of the scanning data sources. Terraform
+// Plugin SDK v1 only decodes the timeouts of resources, so data sources declare
+// the block themselves for scanContext to read it.
+func timeoutsSchema() *schema.Schema {
+	return &schema.Schema{
+		ForceNew: true,
+		Optional: true,
+		Type:     schema.TypeList,
+		MaxItems: 1,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"read": {
+					Optional:     true,
+					Type:         schema.TypeString,
+					ValidateFunc: validateDuration,
+					Description:  "Maximum duration of the whole scan, defaults to 20 minutes",
+				},
+			},
+		},
+	}
+}
+
+// maxScanDurationSchema is the optional deadline of the whole scan.
+func maxScanDurationSchema() *schema.Schema {
+	return &schema.Schema{
+		ForceNew:     true,
+		Optional:     true,
+		Type:         schema.TypeString,
+		ValidateFunc: validateDuration,
+		Description:  "Maximum duration of the whole scan, such as \"2m\"",
+	}
+}
+
+// scanContext returns a context bounding the whole scan by the read timeout,
+// or max_scan_duration when it's shorter, along with the chosen deadline.
+func scanContext(d *schema.ResourceData) (context.Context, context.CancelFunc, time.Duration) {
+	deadline := d.Timeout(schema.TimeoutRead)
+
+	if v, ok := d.GetOk("timeouts.0.read"); ok {
+		if readTimeout, _ := time.ParseDuration(v.(string)); readTimeout > 0 {
+			deadline = readTimeout
+		}
+	}
+
+	if v, ok := d.GetOk("max_scan_duration"); ok {
+		if maxScanDuration, _ := time.ParseDuration(v.(string)); maxScanDuration > 0 && maxScanDuration < deadline {
+			deadline = maxScanDuration
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), deadline)
+	return ctx, cancel, deadline
+}
+
+// boundedScan runs the scan within the deadline of scanContext, returning an
+// error when it doesn't complete in time.
+func boundedScan(d *schema.ResourceData, meta interface{}, ipAddresses []string, ports []int) ([]scanner.PortScanResult, error) {
+	ctx, cancel, deadline := scanContext(d)
+	defer cancel()
+
+	results, err := runScan(ctx, d, meta, ipAddresses, ports)
+	if err != nil {
+		return nil, err
+	}
+
+	if total := len(ipAddresses) * len(ports); len(results) < total {
+		return nil, fmt.Errorf("scan didn't complete within %s, only %d of the %d ports were scanned", deadline, len(results), total)
+	}
+
+	return results, nil
+}
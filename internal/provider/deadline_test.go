@@ -0,0 +1,32 @@
+package provider
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+func Test_boundedScan(t *testing.T) {
+	config := map[string]interface{}{
+		"targets": []interface{}{"127.0.0.1"},
+		"to_port": 65535,
+		"timeouts": []interface{}{
+			map[string]interface{}{"read": "1ns"},
+		},
+	}
+
+	d := schema.TestResourceDataRaw(t, dataSourcePortScanDiff().Schema, config)
+	if err := dataSourcePortScanDiffRead(d, &providerConfig{}); err == nil || !strings.Contains(err.Error(), "didn't complete within 1ns") {
+		t.Fatalf("Expected the read timeout to cut the scan off, got %v", err)
+	}
+
+	// resources without a timeouts block still get the default read timeout
+	d = schema.TestResourceDataRaw(t, resourcePortScanMonitor().Schema, map[string]interface{}{
+		"ip_address": "127.0.0.1",
+		"ports":      []interface{}{1},
+	})
+	if _, err := boundedScan(d, &providerConfig{}, []string{"127.0.0.1"}, portsToScan(d)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,162 @@
+package provider
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
+	scanner "github.com/picatz/terraform-provider-port-scan/internal/provider/port-scanner"
+	"golang.org/x/crypto/ssh"
+)
+
+// sshBastionSchema is the optional SSH bastion block shared by the data sources
+// and resources, used to scan hosts that aren't publicly available.
+func sshBastionSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:     schema.TypeList,
+		Optional: true,
+		ForceNew: true,
+		MaxItems: 1,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"ip_address": {
+					Type:        schema.TypeString,
+					Required:    true,
+					ForceNew:    true,
+					Description: "SSH bastion IP address",
+				},
+				"port": {
+					Type:         schema.TypeInt,
+					Default:      22,
+					Optional:     true,
+					ForceNew:     true,
+					ValidateFunc: validation.IsPortNumber,
+					Description:  "SSH port",
+				},
+				"user": {
+					Type:        schema.TypeString,
+					Default:     "root",
+					Optional:    true,
+					ForceNew:    true,
+					Description: "SSH username",
+				},
+				"password": {
+					Type:         schema.TypeString,
+					Sensitive:    true,
+					Optional:     true,
+					ForceNew:     true,
+					ExactlyOneOf: []string{"ssh_bastion.0.password", "ssh_bastion.0.private_key"},
+					Description:  "SSH password",
+				},
+				"private_key": {
+					Type:         schema.TypeString,
+					Sensitive:    true,
+					Optional:     true,
+					ForceNew:     true,
+					ExactlyOneOf: []string{"ssh_bastion.0.password", "ssh_bastion.0.private_key"},
+					Description:  "PEM encoded SSH private key",
+				},
+				"host_key": {
+					Type:          schema.TypeString,
+					Sensitive:     true,
+					Optional:      true,
+					ForceNew:      true,
+					ConflictsWith: []string{"ssh_bastion.0.insecure_ignore_host_key"},
+					Description:   "Base64 encoded SSH bastion host key",
+				},
+				"insecure_ignore_host_key": {
+					Type:          schema.TypeBool,
+					Sensitive:     true,
+					Optional:      true,
+					ForceNew:      true,
+					ConflictsWith: []string{"ssh_bastion.0.host_key"},
+					Description:   "Skip SSH bastion host key checkinng",
+				},
+			},
+		},
+	}
+}
+
+// Dialer types, as reported by the dialer attribute.
+const (
+	dialerDirect     = "direct"
+	dialerSSHBastion = "ssh_bastion"
+)
+
+// dialerType returns the type of dialer newDialer creates.
+func dialerType(d *schema.ResourceData) string {
+	if _, ok := d.GetOk("ssh_bastion"); ok {
+		return dialerSSHBastion
+	}
+	return dialerDirect
+}
+
+// dialerIdentity describes where the connections are made from, without any
+// credentials, such as "ssh_bastion:root@192.168.1.1:22".
+func dialerIdentity(d *schema.ResourceData) string {
+	if dialerType(d) == dialerDirect {
+		return dialerDirect
+	}
+
+	return fmt.Sprintf(
+		"%s:%s@%s",
+		dialerSSHBastion,
+		d.Get("ssh_bastion.0.user").(string),
+		net.JoinHostPort(d.Get("ssh_bastion.0.ip_address").(string), strconv.Itoa(d.Get("ssh_bastion.0.port").(int))),
+	)
+}
+
+// newDialer returns a direct dialer, or an SSH bastion dialer when the
+// ssh_bastion block is configured. The dialer must be closed by the caller.
+func newDialer(d *schema.ResourceData) (scanner.Dialer, error) {
+	// check if using SSH bastion
+	if _, ok := d.GetOk("ssh_bastion"); !ok {
+		return scanner.NewDialer(), nil
+	}
+
+	var (
+		bastionConnectTimeout time.Duration = 2 * time.Minute
+		bastionUser           string        = d.Get("ssh_bastion.0.user").(string)
+		bastionAddress        string        = fmt.Sprintf(
+			"%s:%d",
+			d.Get("ssh_bastion.0.ip_address").(string),
+			d.Get("ssh_bastion.0.port").(int),
+		)
+		sshClientConfig *ssh.ClientConfig = &ssh.ClientConfig{
+			Timeout: bastionConnectTimeout,
+			User:    bastionUser,
+			Auth:    []ssh.AuthMethod{},
+		}
+	)
+
+	// check if known host key or insecure ignore host key
+	if v, ok := d.GetOk("ssh_bastion.0.host_key"); ok {
+		sshClientConfig.HostKeyCallback = scanner.SSHHostKeyCallback(v.(string))
+	} else {
+		insecureHostKeyCheck := d.Get("ssh_bastion.0.insecure_ignore_host_key").(bool)
+		if insecureHostKeyCheck {
+			sshClientConfig.HostKeyCallback = ssh.InsecureIgnoreHostKey()
+		}
+	}
+
+	// if using ssh key
+	if _, ok := d.GetOk("ssh_bastion.0.private_key"); ok {
+		pemEncodedPrivateKey := d.Get("ssh_bastion.0.private_key").(string)
+		authMethod, err := scanner.SSHPrivateKeyAuth(pemEncodedPrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		sshClientConfig.Auth = append(sshClientConfig.Auth, authMethod)
+	} else { // using password
+		if _, ok := d.GetOk("ssh_bastion.0.password"); ok {
+			sshClientConfig.Auth = append(sshClientConfig.Auth, ssh.Password(d.Get("ssh_bastion.0.password").(string)))
+		} else { // no idea what we're using
+			return nil, fmt.Errorf("no SSH private_key or password provided")
+		}
+	}
+
+	return scanner.NewSSHBastionScanner(bastionAddress, sshClientConfig)
+}
@@ -0,0 +1,135 @@
+package provider
+
+import (
+	"os"
+	"sort"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	scanner "github.com/picatz/terraform-provider-port-scan/internal/provider/port-scanner"
+)
+
+// baseline is the state of each port of each host in a previous scan. Ports
+// of the empty IP address apply to every host. Hosts are matched by the
+// address they were scanned as, which for hostnames is the name itself, since
+// targets aren't resolved when they're expanded.
+type baseline map[string]map[int]scanner.PortState
+
+// state returns the state of the port of the host in the baseline.
+func (b baseline) state(ip string, port int) (scanner.PortState, bool) {
+	if state, ok := b[ip][port]; ok {
+		return state, true
+	}
+	state, ok := b[""][port]
+	return state, ok
+}
+
+// add sets the state of the port of the host.
+func (b baseline) add(ip string, port int, state scanner.PortState) {
+	if b[ip] == nil {
+		b[ip] = map[int]scanner.PortState{}
+	}
+	b[ip][port] = state
+}
+
+// openPorts returns the ports open in the baseline on any of the hosts.
+func (b baseline) openPorts(ipAddresses []string) []int {
+	ports := []int{}
+	for _, ip := range append([]string{""}, ipAddresses...) {
+		for port, state := range b[ip] {
+			if state == scanner.PortStateOpen {
+				ports = append(ports, port)
+			}
+		}
+	}
+	sort.Ints(ports)
+	return ports
+}
+
+// readBaseline reads the baseline from either the baseline_file JSON report,
+// or the baseline_results. Without either of them, the baseline is empty.
+func readBaseline(d *schema.ResourceData) (baseline, error) {
+	b := baseline{}
+
+	if v, ok := d.GetOk("baseline_file"); ok {
+		f, err := os.Open(v.(string))
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		report, err := scanner.ReadJSON(f)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, result := range report.Results {
+			b.add(result.IP, result.Port, result.State)
+		}
+		return b, nil
+	}
+
+	for _, v := range d.Get("baseline_results").([]interface{}) {
+		result := v.(map[string]interface{})
+		b.add(result["ip_address"].(string), result["port"].(int), scanner.PortState(result["state"].(string)))
+	}
+
+	return b, nil
+}
+
+// portChange is a port whose state changed since the baseline.
+type portChange struct {
+	port     int
+	previous scanner.PortState
+	current  scanner.PortState
+}
+
+// hostDiff is the differences between the baseline and the scan of a host.
+type hostDiff struct {
+	ip      string
+	opened  []int
+	closed  []int
+	changes []portChange
+}
+
+// changed returns true when any port of the host was opened or closed.
+func (h *hostDiff) changed() bool {
+	return len(h.opened) > 0 || len(h.closed) > 0
+}
+
+// diffResults compares the sorted scan results of each host to the baseline.
+// Ports missing from the baseline are only reported when they're open, and
+// ports of the baseline that weren't scanned are ignored.
+func diffResults(b baseline, ipAddresses []string, results []scanner.PortScanResult) []*hostDiff {
+	var (
+		diffs  = []*hostDiff{}
+		byHost = map[string]*hostDiff{}
+	)
+
+	for _, ip := range ipAddresses {
+		diff := &hostDiff{ip: ip, opened: []int{}, closed: []int{}, changes: []portChange{}}
+		diffs = append(diffs, diff)
+		byHost[ip] = diff
+	}
+
+	for _, result := range results {
+		diff, ok := byHost[result.IP]
+		if !ok {
+			continue
+		}
+
+		previous, known := b.state(result.IP, result.Port)
+
+		switch {
+		case result.State == scanner.PortStateOpen && previous != scanner.PortStateOpen:
+			diff.opened = append(diff.opened, result.Port)
+		case result.State != scanner.PortStateOpen && previous == scanner.PortStateOpen:
+			diff.closed = append(diff.closed, result.Port)
+		}
+
+		if known && previous != result.State {
+			diff.changes = append(diff.changes, portChange{port: result.Port, previous: previous, current: result.State})
+		}
+	}
+
+	return diffs
+}
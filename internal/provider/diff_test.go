@@ -0,0 +1,163 @@
+package provider
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+	scanner "github.com/picatz/terraform-provider-port-scan/internal/provider/port-scanner"
+)
+
+func Test_diffResults(t *testing.T) {
+	b := baseline{}
+	b.add("10.0.0.1", 22, scanner.PortStateOpen)
+	b.add("10.0.0.1", 80, scanner.PortStateOpen)
+	b.add("10.0.0.1", 443, scanner.PortStateClosed)
+	b.add("", 8080, scanner.PortStateClosed)
+
+	if ports := b.openPorts([]string{"10.0.0.1"}); !reflect.DeepEqual(ports, []int{22, 80}) {
+		t.Errorf("Expected the open ports of the baseline, got %v", ports)
+	}
+
+	results := []scanner.PortScanResult{
+		{IP: "10.0.0.1", Port: 22, Open: true, State: scanner.PortStateOpen},
+		{IP: "10.0.0.1", Port: 80, State: scanner.PortStateFiltered},
+		{IP: "10.0.0.1", Port: 443, State: scanner.PortStateFiltered},
+		{IP: "10.0.0.1", Port: 3306, Open: true, State: scanner.PortStateOpen},
+		{IP: "10.0.0.1", Port: 5432, State: scanner.PortStateClosed},
+		{IP: "10.0.0.2", Port: 8080, Open: true, State: scanner.PortStateOpen},
+	}
+
+	diffs := diffResults(b, []string{"10.0.0.1", "10.0.0.2"}, results)
+
+	want := []*hostDiff{
+		{
+			ip:     "10.0.0.1",
+			opened: []int{3306},
+			closed: []int{80},
+			changes: []portChange{
+				{port: 80, previous: scanner.PortStateOpen, current: scanner.PortStateFiltered},
+				{port: 443, previous: scanner.PortStateClosed, current: scanner.PortStateFiltered},
+			},
+		},
+		{
+			ip:      "10.0.0.2",
+			opened:  []int{8080},
+			closed:  []int{},
+			changes: []portChange{{port: 8080, previous: scanner.PortStateClosed, current: scanner.PortStateOpen}},
+		},
+	}
+	if !reflect.DeepEqual(diffs, want) {
+		t.Errorf("Expected %+v, got %+v", want, diffs)
+	}
+}
+
+func Test_diffResults_hostname(t *testing.T) {
+	b := baseline{}
+	b.add("localhost", 22, scanner.PortStateOpen)
+	b.add("127.0.0.1", 80, scanner.PortStateOpen)
+
+	results := []scanner.PortScanResult{
+		{IP: "localhost", Port: 22, Open: true, State: scanner.PortStateOpen},
+		{IP: "localhost", Port: 80, Open: true, State: scanner.PortStateOpen},
+	}
+
+	// hostnames are matched as they were given, not by the address they resolve to
+	diffs := diffResults(b, []string{"localhost"}, results)
+	if !reflect.DeepEqual(diffs[0].opened, []int{80}) || len(diffs[0].closed) != 0 {
+		t.Errorf("Expected only port 80 to be opened on localhost, got %+v", diffs[0])
+	}
+}
+
+func Test_dataSourcePortScanDiffRead(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	config := map[string]interface{}{
+		"targets": []interface{}{"127.0.0.1"},
+		"port":    port,
+		"baseline_results": []interface{}{
+			map[string]interface{}{"port": port, "state": "closed"},
+		},
+	}
+
+	d := schema.TestResourceDataRaw(t, dataSourcePortScanDiff().Schema, config)
+	if err := dataSourcePortScanDiffRead(d, &providerConfig{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !d.Get("changed").(bool) {
+		t.Error("Expected the scan to have changed since the baseline")
+	}
+	if opened := d.Get("hosts.0.opened_ports").([]interface{}); !reflect.DeepEqual(opened, []interface{}{port}) {
+		t.Errorf("Expected port %d to be opened, got %v", port, opened)
+	}
+	if state := d.Get("hosts.0.state_changes.0.previous_state").(string); state != "closed" {
+		t.Errorf("Expected the previous state to be closed, got %q", state)
+	}
+
+	config["fail_on_change"] = true
+
+	d = schema.TestResourceDataRaw(t, dataSourcePortScanDiff().Schema, config)
+	if err := dataSourcePortScanDiffRead(d, &providerConfig{}); err == nil || !strings.Contains(err.Error(), "opened ports") {
+		t.Fatalf("Expected an error listing the opened port, got %v", err)
+	}
+}
+
+func Test_dataSourcePortScanDiffRead_portScanResults(t *testing.T) {
+	// Terraform only assigns the results to baseline_results when both have
+	// the same attributes
+	results := dataSourcePortScan().CoreConfigSchema().Attributes["results"]
+	baselineResults, ok := dataSourcePortScanDiff().CoreConfigSchema().Attributes["baseline_results"]
+	if !ok {
+		t.Fatal("Expected baseline_results to be an attribute")
+	}
+	if !baselineResults.Type.Equals(results.Type) {
+		t.Fatalf("Expected baseline_results to have the type of results %#v, got %#v", results.Type, baselineResults.Type)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	scan := schema.TestResourceDataRaw(t, dataSourcePortScan().Schema, map[string]interface{}{
+		"ip_address":     "127.0.0.1",
+		"ports":          []interface{}{port, 1},
+		"probers":        []interface{}{"banner"},
+		"banner_timeout": "100ms",
+	})
+	if err := dataSourcePortScanRead(scan, &providerConfig{}); err != nil {
+		t.Fatal(err)
+	}
+
+	config := map[string]interface{}{
+		"targets":          []interface{}{"127.0.0.1", "127.0.0.2"},
+		"ports":            []interface{}{port, 1},
+		"baseline_results": scan.Get("results"),
+	}
+
+	if _, errs := dataSourcePortScanDiff().Validate(terraform.NewResourceConfigRaw(config)); len(errs) > 0 {
+		t.Fatalf("Expected the results of port_scan to be a valid baseline, got %v", errs)
+	}
+
+	d := schema.TestResourceDataRaw(t, dataSourcePortScanDiff().Schema, config)
+	if err := dataSourcePortScanDiffRead(d, &providerConfig{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if d.Get("changed").(bool) {
+		t.Errorf("Expected no change since the baseline, got %v opened and %v closed", d.Get("opened_endpoints"), d.Get("closed_endpoints"))
+	}
+}
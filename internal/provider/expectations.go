@@ -0,0 +1,143 @@
+package provider
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	scanner "github.com/picatz/terraform-provider-port-scan/internal/provider/port-scanner"
+)
+
+// expectedPorts returns the expected open and closed ports, which are always scanned.
+func expectedPorts(d *schema.ResourceData) (open, closed []int) {
+	if v, ok := d.GetOk("expected_open_ports"); ok {
+		open = convertIntArr(v.([]interface{}))
+	}
+	if v, ok := d.GetOk("expected_closed_ports"); ok {
+		closed = convertIntArr(v.([]interface{}))
+	}
+	return
+}
+
+// validateExpectedPorts checks that no port is expected to be both open and
+// closed. Data sources don't support CustomizeDiff, so it's checked on read.
+func validateExpectedPorts(expectedOpen, expectedClosed []int) error {
+	isExpectedOpen := intSet(expectedOpen)
+
+	both := []int{}
+	for port := range intSet(expectedClosed) {
+		if isExpectedOpen[port] {
+			both = append(both, port)
+		}
+	}
+
+	if len(both) > 0 {
+		sort.Ints(both)
+		return fmt.Errorf("ports [%s] can't be listed in both expected_open_ports and expected_closed_ports", joinInts(both))
+	}
+	return nil
+}
+
+// portDifferences compares the observed open ports to the expected ports.
+//
+// When expectedOpen is empty, only the expectedClosed ports can be unexpected.
+// Expected ports that weren't scanned, such as when a scan with partial
+// results runs out of time, aren't missing but unscanned.
+func portDifferences(open, scanned, expectedOpen, expectedClosed []int) (unexpected, missing, unscanned []int) {
+	var (
+		isOpen           = intSet(open)
+		isScanned        = intSet(scanned)
+		isExpectedOpen   = intSet(expectedOpen)
+		isExpectedClosed = intSet(expectedClosed)
+	)
+
+	unexpected, missing, unscanned = []int{}, []int{}, []int{}
+
+	for port := range isOpen {
+		if isExpectedClosed[port] || (len(expectedOpen) > 0 && !isExpectedOpen[port]) {
+			unexpected = append(unexpected, port)
+		}
+	}
+
+	for port := range isExpectedOpen {
+		if !isOpen[port] && isScanned[port] {
+			missing = append(missing, port)
+		}
+	}
+
+	for _, expected := range []map[int]bool{isExpectedOpen, isExpectedClosed} {
+		for port := range expected {
+			if !isScanned[port] {
+				unscanned = append(unscanned, port)
+			}
+		}
+	}
+
+	sort.Ints(unexpected)
+	sort.Ints(missing)
+	sort.Ints(unscanned)
+	return
+}
+
+// setExpectations sets the computed differences between the observed open
+// ports and the expected ports, returning an error listing them if
+// fail_on_mismatch is enabled. Expected ports that weren't scanned don't
+// count as a mismatch, they're listed in unscanned_expected_ports instead.
+func setExpectations(d *schema.ResourceData, results []scanner.PortScanResult) error {
+	expectedOpen, expectedClosed := expectedPorts(d)
+
+	scanned := []int{}
+	for _, result := range results {
+		scanned = append(scanned, result.Port)
+	}
+
+	unexpected, missing, unscanned := portDifferences(openPorts(results), scanned, expectedOpen, expectedClosed)
+
+	if err := d.Set("unexpected_open_ports", unexpected); err != nil {
+		return err
+	}
+	if err := d.Set("missing_open_ports", missing); err != nil {
+		return err
+	}
+	if err := d.Set("unscanned_expected_ports", unscanned); err != nil {
+		return err
+	}
+
+	if len(unscanned) > 0 {
+		log.Printf("[WARN] expected ports %s of %s weren't scanned before the deadline", joinInts(unscanned), d.Get("ip_address").(string))
+	}
+
+	if d.Get("fail_on_mismatch").(bool) && (len(unexpected) > 0 || len(missing) > 0) {
+		problems := []string{}
+		if len(unexpected) > 0 {
+			problems = append(problems, fmt.Sprintf("unexpected open ports: %s", joinInts(unexpected)))
+		}
+		if len(missing) > 0 {
+			problems = append(problems, fmt.Sprintf("missing open ports: %s", joinInts(missing)))
+		}
+		return fmt.Errorf("%s ports don't match the expected ports: %s", d.Get("ip_address").(string), strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// intSet converts a list of ints to a set.
+func intSet(ints []int) map[int]bool {
+	set := map[int]bool{}
+	for _, i := range ints {
+		set[i] = true
+	}
+	return set
+}
+
+// joinInts formats a list of ints as a comma separated string.
+func joinInts(ints []int) string {
+	strs := []string{}
+	for _, i := range ints {
+		strs = append(strs, strconv.Itoa(i))
+	}
+	return strings.Join(strs, ", ")
+}
@@ -0,0 +1,129 @@
+package provider
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+func Test_portDifferences(t *testing.T) {
+	tests := []struct {
+		name           string
+		open           []int
+		scanned        []int
+		expectedOpen   []int
+		expectedClosed []int
+		unexpected     []int
+		missing        []int
+		unscanned      []int
+	}{
+		{
+			name:       "no expectations",
+			open:       []int{22, 80},
+			scanned:    []int{22, 80},
+			unexpected: []int{},
+			missing:    []int{},
+			unscanned:  []int{},
+		},
+		{
+			name:         "matching",
+			open:         []int{22, 80},
+			scanned:      []int{22, 80},
+			expectedOpen: []int{80, 22},
+			unexpected:   []int{},
+			missing:      []int{},
+			unscanned:    []int{},
+		},
+		{
+			name:         "unexpected and missing",
+			open:         []int{22, 3306},
+			scanned:      []int{22, 443, 3306},
+			expectedOpen: []int{22, 443},
+			unexpected:   []int{3306},
+			missing:      []int{443},
+			unscanned:    []int{},
+		},
+		{
+			name:           "expected closed only",
+			open:           []int{22, 23},
+			scanned:        []int{22, 23, 3389},
+			expectedClosed: []int{23, 3389},
+			unexpected:     []int{23},
+			missing:        []int{},
+			unscanned:      []int{},
+		},
+		{
+			name:           "partial results",
+			open:           []int{22},
+			scanned:        []int{22, 80},
+			expectedOpen:   []int{22, 80, 443},
+			expectedClosed: []int{3389},
+			unexpected:     []int{},
+			missing:        []int{80},
+			unscanned:      []int{443, 3389},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			unexpected, missing, unscanned := portDifferences(test.open, test.scanned, test.expectedOpen, test.expectedClosed)
+			if !reflect.DeepEqual(unexpected, test.unexpected) {
+				t.Errorf("Expected unexpected open ports %v, got %v", test.unexpected, unexpected)
+			}
+			if !reflect.DeepEqual(missing, test.missing) {
+				t.Errorf("Expected missing open ports %v, got %v", test.missing, missing)
+			}
+			if !reflect.DeepEqual(unscanned, test.unscanned) {
+				t.Errorf("Expected unscanned expected ports %v, got %v", test.unscanned, unscanned)
+			}
+		})
+	}
+}
+
+func Test_validateExpectedPorts(t *testing.T) {
+	if err := validateExpectedPorts([]int{22, 443}, []int{23}); err != nil {
+		t.Error(err)
+	}
+
+	err := validateExpectedPorts([]int{22, 443, 80}, []int{80, 23, 22})
+	if err == nil || !strings.Contains(err.Error(), "ports [22, 80]") {
+		t.Errorf("Expected an error listing the overlapping ports, got %v", err)
+	}
+}
+
+func Test_dataSourcePortScanRead_overlappingExpectedPorts(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, dataSourcePortScan().Schema, map[string]interface{}{
+		"ip_address":            "127.0.0.1",
+		"port":                  22,
+		"expected_open_ports":   []interface{}{22},
+		"expected_closed_ports": []interface{}{22},
+	})
+
+	if err := dataSourcePortScanRead(d, &providerConfig{}); err == nil {
+		t.Fatal("Expected an error when a port is expected to be both open and closed")
+	}
+}
+
+func Test_dataSourcePortScanRead_unscannedExpectedPorts(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, dataSourcePortScan().Schema, map[string]interface{}{
+		"ip_address":          "127.0.0.1",
+		"to_port":             65535,
+		"expected_open_ports": []interface{}{22},
+		"fail_on_mismatch":    true,
+		"max_scan_duration":   "1ns",
+		"partial_results":     true,
+	})
+
+	if err := dataSourcePortScanRead(d, &providerConfig{}); err != nil {
+		t.Fatalf("Expected unscanned ports to not be a mismatch, got %v", err)
+	}
+
+	if missing := d.Get("missing_open_ports").([]interface{}); len(missing) != 0 {
+		t.Errorf("Expected no missing open ports, got %v", missing)
+	}
+	if unscanned := d.Get("unscanned_expected_ports").([]interface{}); !reflect.DeepEqual(unscanned, []interface{}{22}) {
+		t.Errorf("Expected port 22 to be reported as unscanned, got %v", unscanned)
+	}
+}
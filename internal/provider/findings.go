@@ -0,0 +1,138 @@
+package provider
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
+	scanner "github.com/picatz/terraform-provider-port-scan/internal/provider/port-scanner"
+)
+
+// riskIDs returns the IDs of the known-dangerous exposures, which are the
+// IDs of their findings.
+func riskIDs() []string {
+	ids := []string{}
+	for _, risk := range scanner.Risks() {
+		ids = append(ids, risk.ID)
+	}
+	return ids
+}
+
+// suppressionSchema lists the findings that have been accepted.
+func suppressionSchema() *schema.Schema {
+	return &schema.Schema{
+		ForceNew:    true,
+		Optional:    true,
+		Type:        schema.TypeList,
+		Description: "Findings that are accepted, such as services only reachable over a VPN",
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"id": {
+					Type:         schema.TypeString,
+					Required:     true,
+					ValidateFunc: validation.StringInSlice(riskIDs(), false),
+					Description:  "ID of the finding",
+				},
+				"reason": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					Description: "Why the finding is accepted",
+				},
+			},
+		},
+	}
+}
+
+// findingsSchema is the computed known-dangerous exposures found by the scan.
+func findingsSchema() *schema.Schema {
+	return &schema.Schema{
+		Computed:    true,
+		Type:        schema.TypeList,
+		Description: "Known-dangerous services found open",
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"id": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+				"port": {
+					Type:     schema.TypeInt,
+					Computed: true,
+				},
+				"endpoint": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+				"service": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+				"severity": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+				"rationale": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+				"suppressed": {
+					Type:     schema.TypeBool,
+					Computed: true,
+				},
+				"suppression_reason": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+			},
+		},
+	}
+}
+
+// findingObjects converts the open ports of known-dangerous services to the
+// objects of the findings attribute. Suppressed findings are kept, flagged
+// with the reason they were suppressed.
+func findingObjects(results []scanner.PortScanResult, suppressions map[string]string) []interface{} {
+	findings := []interface{}{}
+	for _, result := range results {
+		if !result.Open {
+			continue
+		}
+
+		risk, ok := scanner.RiskyService(result.Port)
+		if !ok {
+			continue
+		}
+
+		reason, suppressed := suppressions[risk.ID]
+
+		findings = append(findings, map[string]interface{}{
+			"id":                 risk.ID,
+			"port":               result.Port,
+			"endpoint":           net.JoinHostPort(result.IP, strconv.Itoa(result.Port)),
+			"service":            risk.Service,
+			"severity":           string(risk.Severity),
+			"rationale":          risk.Rationale,
+			"suppressed":         suppressed,
+			"suppression_reason": reason,
+		})
+	}
+	return findings
+}
+
+// suppressions returns the reasons of the suppression blocks, keyed by the ID
+// of the suppressed findings.
+func suppressions(d resourceGetter) map[string]string {
+	suppressions := map[string]string{}
+	for _, v := range d.Get("suppression").([]interface{}) {
+		suppression := v.(map[string]interface{})
+		suppressions[suppression["id"].(string)] = suppression["reason"].(string)
+	}
+	return suppressions
+}
+
+// setFindings sets the findings attribute, with the suppressions of the
+// suppression blocks.
+func setFindings(d *schema.ResourceData, results []scanner.PortScanResult) error {
+	return d.Set("findings", findingObjects(results, suppressions(d)))
+}
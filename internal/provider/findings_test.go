@@ -0,0 +1,63 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	scanner "github.com/picatz/terraform-provider-port-scan/internal/provider/port-scanner"
+)
+
+func Test_setFindings(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, dataSourcePortScan().Schema, map[string]interface{}{
+		"ip_address": "10.0.0.1",
+		"suppression": []interface{}{
+			map[string]interface{}{"id": "redis", "reason": "Only reachable over the VPN"},
+		},
+	})
+
+	results := []scanner.PortScanResult{
+		{IP: "10.0.0.1", Port: 22, Open: true, State: scanner.PortStateOpen},
+		{IP: "10.0.0.1", Port: 23, State: scanner.PortStateClosed},
+		{IP: "10.0.0.1", Port: 2375, Open: true, State: scanner.PortStateOpen},
+		{IP: "10.0.0.1", Port: 6379, Open: true, State: scanner.PortStateOpen},
+	}
+
+	if err := setFindings(d, results); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := d.Get("findings.#").(int); n != 2 {
+		t.Fatalf("Expected 2 findings, got %d", n)
+	}
+
+	if id := d.Get("findings.0.id").(string); id != "docker" {
+		t.Errorf("Expected the first finding to be docker, got %q", id)
+	}
+	if severity := d.Get("findings.0.severity").(string); severity != "critical" {
+		t.Errorf("Expected the docker finding to be critical, got %q", severity)
+	}
+	if endpoint := d.Get("findings.0.endpoint").(string); endpoint != "10.0.0.1:2375" {
+		t.Errorf("Expected the docker finding endpoint to be 10.0.0.1:2375, got %q", endpoint)
+	}
+	if d.Get("findings.0.suppressed").(bool) {
+		t.Errorf("Expected the docker finding not to be suppressed")
+	}
+
+	if !d.Get("findings.1.suppressed").(bool) {
+		t.Errorf("Expected the redis finding to be suppressed")
+	}
+	if reason := d.Get("findings.1.suppression_reason").(string); reason != "Only reachable over the VPN" {
+		t.Errorf("Expected the suppression reason, got %q", reason)
+	}
+}
+
+func Test_suppressionSchema_unknownID(t *testing.T) {
+	id := suppressionSchema().Elem.(*schema.Resource).Schema["id"]
+
+	if _, errs := id.ValidateFunc("ftp", "id"); len(errs) == 0 {
+		t.Errorf("Expected an error for a finding not in the catalog")
+	}
+	if _, errs := id.ValidateFunc("telnet", "id"); len(errs) != 0 {
+		t.Errorf("Expected no error for a finding in the catalog, got %v", errs)
+	}
+}
@@ -0,0 +1,145 @@
+package provider
+
+import (
+	"fmt"
+	"sort"
+
+	scanner "github.com/picatz/terraform-provider-port-scan/internal/provider/port-scanner"
+)
+
+// firewallRule is a declared ingress rule allowing a range of TCP ports.
+type firewallRule struct {
+	description string
+	from        int
+	to          int
+}
+
+// String returns the port range of the rule, with its description.
+func (r *firewallRule) String() string {
+	ports := fmt.Sprintf("%d-%d", r.from, r.to)
+	if r.from == r.to {
+		ports = fmt.Sprintf("%d", r.from)
+	}
+	if r.description == "" {
+		return ports
+	}
+	return fmt.Sprintf("%s (%s)", ports, r.description)
+}
+
+// allows returns true when the port is in the range of the rule.
+func (r *firewallRule) allows(port int) bool {
+	return port >= r.from && port <= r.to
+}
+
+// declaredPorts returns every port allowed by the rules, sorted.
+func declaredPorts(rules []*firewallRule) []int {
+	seen := map[int]bool{}
+	ports := []int{}
+	for _, rule := range rules {
+		for port := rule.from; port <= rule.to; port++ {
+			if !seen[port] {
+				seen[port] = true
+				ports = append(ports, port)
+			}
+		}
+	}
+	sort.Ints(ports)
+	return ports
+}
+
+// declared returns true when any of the rules allows the port.
+func declared(rules []*firewallRule, port int) bool {
+	for _, rule := range rules {
+		if rule.allows(port) {
+			return true
+		}
+	}
+	return false
+}
+
+// sampleUndeclaredPorts returns up to size ports none of the rules allow,
+// to check that the firewall blocks them. The well-known ports are sampled
+// first, since they're the most likely to be left open, then ports spread
+// evenly over the rest of the port range. The sample is always the same for
+// the same rules, so the scan doesn't change between plans.
+func sampleUndeclaredPorts(rules []*firewallRule, size int) []int {
+	var (
+		seen   = map[int]bool{}
+		sample = []int{}
+	)
+
+	add := func(port int) {
+		if len(sample) < size && !seen[port] && !declared(rules, port) {
+			seen[port] = true
+			sample = append(sample, port)
+		}
+	}
+
+	for _, port := range scanner.WellKnownPorts() {
+		add(port)
+	}
+
+	if remaining := size - len(sample); remaining > 0 {
+		step := 65535 / remaining
+		if step < 1 {
+			step = 1
+		}
+		for port := 1; port <= 65535 && len(sample) < size; port += step {
+			add(port)
+		}
+		// fill the gaps left by declared and already sampled ports
+		for port := 1; port <= 65535 && len(sample) < size; port++ {
+			add(port)
+		}
+	}
+
+	sort.Ints(sample)
+	return sample
+}
+
+// firewallRuleResult is the observed state of a declared rule.
+type firewallRuleResult struct {
+	rule *firewallRule
+	// state is "open" when any port of the rule is open on any host,
+	// "closed" when the firewall let the connection through but nothing was
+	// listening, and "filtered" when every connection was dropped
+	state     scanner.PortState
+	openPorts []int
+}
+
+// effective returns true when the rule lets traffic reach an open port.
+func (r *firewallRuleResult) effective() bool {
+	return r.state == scanner.PortStateOpen
+}
+
+// verifyFirewall compares the results of the scan to the declared rules,
+// returning the state of each rule and the open ports none of them allow.
+func verifyFirewall(rules []*firewallRule, results []scanner.PortScanResult) ([]*firewallRuleResult, []scanner.PortScanResult) {
+	ruleResults := []*firewallRuleResult{}
+	for _, rule := range rules {
+		ruleResult := &firewallRuleResult{rule: rule, state: scanner.PortStateFiltered, openPorts: []int{}}
+		for _, result := range results {
+			if !rule.allows(result.Port) {
+				continue
+			}
+			switch {
+			case result.Open:
+				ruleResult.state = scanner.PortStateOpen
+				ruleResult.openPorts = uniquePorts(ruleResult.openPorts, []int{result.Port})
+			case result.State == scanner.PortStateClosed && ruleResult.state != scanner.PortStateOpen:
+				ruleResult.state = scanner.PortStateClosed
+			}
+		}
+		sort.Ints(ruleResult.openPorts)
+		ruleResults = append(ruleResults, ruleResult)
+	}
+
+	leaks := []scanner.PortScanResult{}
+	for _, result := range results {
+		if result.Open && !declared(rules, result.Port) {
+			leaks = append(leaks, result)
+		}
+	}
+
+	return ruleResults, leaks
+}
@@ -0,0 +1,167 @@
+package provider
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	scanner "github.com/picatz/terraform-provider-port-scan/internal/provider/port-scanner"
+)
+
+func Test_sampleUndeclaredPorts(t *testing.T) {
+	rules := []*firewallRule{
+		{from: 22, to: 22},
+		{from: 1, to: 1000},
+	}
+
+	sample := sampleUndeclaredPorts(rules, 50)
+	if len(sample) != 50 {
+		t.Fatalf("Expected 50 sampled ports, got %d", len(sample))
+	}
+	for _, port := range sample {
+		if declared(rules, port) {
+			t.Errorf("Expected only undeclared ports to be sampled, got %d", port)
+		}
+	}
+	if sample[0] != 1080 {
+		t.Errorf("Expected the well-known ports to be sampled first, got %v", sample)
+	}
+
+	if again := sampleUndeclaredPorts(rules, 50); !reflect.DeepEqual(sample, again) {
+		t.Errorf("Expected the same sample for the same rules")
+	}
+
+	all := sampleUndeclaredPorts(rules, 65535)
+	if len(all) != 65535-1000 {
+		t.Errorf("Expected every undeclared port to be sampled, got %d", len(all))
+	}
+}
+
+func Test_verifyFirewall(t *testing.T) {
+	rules := []*firewallRule{
+		{description: "ssh", from: 22, to: 22},
+		{description: "app", from: 8000, to: 8010},
+		{from: 9000, to: 9000},
+	}
+
+	results := []scanner.PortScanResult{
+		{IP: "10.0.0.1", Port: 22, Open: true, State: scanner.PortStateOpen},
+		{IP: "10.0.0.1", Port: 3306, Open: true, State: scanner.PortStateOpen},
+		{IP: "10.0.0.1", Port: 8000, State: scanner.PortStateClosed},
+		{IP: "10.0.0.1", Port: 8001, State: scanner.PortStateFiltered},
+		{IP: "10.0.0.1", Port: 9000, State: scanner.PortStateFiltered},
+		{IP: "10.0.0.2", Port: 22, State: scanner.PortStateClosed},
+	}
+
+	ruleResults, leaks := verifyFirewall(rules, results)
+
+	states := []scanner.PortState{}
+	for _, ruleResult := range ruleResults {
+		states = append(states, ruleResult.state)
+	}
+	if want := []scanner.PortState{scanner.PortStateOpen, scanner.PortStateClosed, scanner.PortStateFiltered}; !reflect.DeepEqual(states, want) {
+		t.Errorf("Expected rule states %v, got %v", want, states)
+	}
+	if !reflect.DeepEqual(ruleResults[0].openPorts, []int{22}) {
+		t.Errorf("Expected port 22 to be open for the ssh rule, got %v", ruleResults[0].openPorts)
+	}
+
+	if len(leaks) != 1 || leaks[0].Port != 3306 {
+		t.Errorf("Expected port 3306 to leak, got %+v", leaks)
+	}
+
+	if s := ruleResults[1].rule.String(); s != "8000-8010 (app)" {
+		t.Errorf("Expected the rule to be described as its port range and description, got %q", s)
+	}
+}
+
+func Test_dataSourcePortScanFirewallRead(t *testing.T) {
+	listen := func() (net.Listener, int) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return listener, listener.Addr().(*net.TCPAddr).Port
+	}
+
+	allowed, allowedPort := listen()
+	defer allowed.Close()
+
+	leaked, leakedPort := listen()
+	defer leaked.Close()
+
+	closed, closedPort := listen()
+	closed.Close()
+
+	config := map[string]interface{}{
+		"targets": []interface{}{"127.0.0.1"},
+		"rule": []interface{}{
+			map[string]interface{}{"description": "app", "from_port": allowedPort},
+			map[string]interface{}{"description": "unused", "from_port": closedPort},
+		},
+		"sample_size":  0,
+		"sample_ports": []interface{}{leakedPort, allowedPort},
+	}
+
+	d := schema.TestResourceDataRaw(t, dataSourcePortScanFirewall().Schema, config)
+	if err := dataSourcePortScanFirewallRead(d, &providerConfig{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if d.Get("passed").(bool) {
+		t.Error("Expected the firewall verification to fail")
+	}
+	if !d.Get("rule.0.effective").(bool) || d.Get("rule.1.effective").(bool) {
+		t.Errorf("Expected only the unused rule to be ineffective")
+	}
+	if state := d.Get("rule.1.state").(string); state != string(scanner.PortStateClosed) {
+		t.Errorf("Expected the unused rule to be closed, got %q", state)
+	}
+	if sampled := d.Get("sampled_ports").([]interface{}); len(sampled) != 1 || sampled[0].(int) != leakedPort {
+		t.Errorf("Expected only the undeclared sample port to be sampled, got %v", sampled)
+	}
+	if leaks := d.Get("leaked_ports").([]interface{}); len(leaks) != 1 || leaks[0].(int) != leakedPort {
+		t.Errorf("Expected port %d to leak, got %v", leakedPort, leaks)
+	}
+
+	config["fail_on_leak"] = true
+
+	d = schema.TestResourceDataRaw(t, dataSourcePortScanFirewall().Schema, config)
+	if err := dataSourcePortScanFirewallRead(d, &providerConfig{}); err == nil || !strings.Contains(err.Error(), "open ports not allowed by any rule") {
+		t.Fatalf("Expected an error listing the leak, got %v", err)
+	}
+}
+
+func Test_firewallRules(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, dataSourcePortScanFirewall().Schema, map[string]interface{}{
+		"targets": []interface{}{"127.0.0.1"},
+		"rule": []interface{}{
+			map[string]interface{}{"from_port": 22},
+			map[string]interface{}{"from_port": 0, "to_port": 0, "protocol": "-1"},
+			map[string]interface{}{"from_port": 80, "to_port": 80, "protocol": "all"},
+		},
+	})
+
+	rules, err := firewallRules(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range [][2]int{{22, 22}, {1, 65535}, {1, 65535}} {
+		if rules[i].from != want[0] || rules[i].to != want[1] {
+			t.Errorf("Expected rule %d to allow %d-%d, got %s", i, want[0], want[1], rules[i])
+		}
+	}
+
+	d = schema.TestResourceDataRaw(t, dataSourcePortScanFirewall().Schema, map[string]interface{}{
+		"targets": []interface{}{"127.0.0.1"},
+		"rule": []interface{}{
+			map[string]interface{}{"from_port": 0, "to_port": 0},
+		},
+	})
+	if _, err := firewallRules(d); err == nil {
+		t.Error("Expected tcp rules to require a port number")
+	}
+}
@@ -0,0 +1,73 @@
+package provider
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	scanner "github.com/picatz/terraform-provider-port-scan/internal/provider/port-scanner"
+)
+
+// ingressRulesSchema is the computed ingress rules allowing the open ports,
+// to bootstrap firewall configurations of existing hosts.
+func ingressRulesSchema() *schema.Schema {
+	return &schema.Schema{
+		Computed:    true,
+		Type:        schema.TypeList,
+		Description: "Minimal ingress rules allowing the open ports, with contiguous ports collapsed into ranges",
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"key": {
+					Type:        schema.TypeString,
+					Computed:    true,
+					Description: "Unique key of the rule, such as \"tcp-8000-8002\", for for_each",
+				},
+				"protocol": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+				"from_port": {
+					Type:     schema.TypeInt,
+					Computed: true,
+				},
+				"to_port": {
+					Type:     schema.TypeInt,
+					Computed: true,
+				},
+				"description": {
+					Type:        schema.TypeString,
+					Computed:    true,
+					Description: "Well-known services of the ports, empty when there are none",
+				},
+			},
+		},
+	}
+}
+
+// ingressRuleObjects collapses the open ports into the fewest contiguous
+// port ranges, sorted by port, as the objects of the ingress_rules attribute.
+func ingressRuleObjects(open []int) []interface{} {
+	rules := []interface{}{}
+	for _, span := range scanner.CollapsePorts(open) {
+		key := fmt.Sprintf("tcp-%d", span.From)
+		if span.To != span.From {
+			key = fmt.Sprintf("tcp-%d-%d", span.From, span.To)
+		}
+
+		services := []string{}
+		for port := span.From; port <= span.To; port++ {
+			if service := scanner.ServiceName(port); service != "" {
+				services = append(services, service)
+			}
+		}
+
+		rules = append(rules, map[string]interface{}{
+			"key":         key,
+			"protocol":    "tcp",
+			"from_port":   span.From,
+			"to_port":     span.To,
+			"description": strings.Join(services, ", "),
+		})
+	}
+	return rules
+}
@@ -0,0 +1,25 @@
+package provider
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_ingressRuleObjects(t *testing.T) {
+	rules := ingressRuleObjects([]int{8001, 22, 8000, 443, 8002, 80, 22})
+
+	want := []interface{}{
+		map[string]interface{}{"key": "tcp-22", "protocol": "tcp", "from_port": 22, "to_port": 22, "description": "ssh"},
+		map[string]interface{}{"key": "tcp-80", "protocol": "tcp", "from_port": 80, "to_port": 80, "description": "http"},
+		map[string]interface{}{"key": "tcp-443", "protocol": "tcp", "from_port": 443, "to_port": 443, "description": "https"},
+		map[string]interface{}{"key": "tcp-8000-8002", "protocol": "tcp", "from_port": 8000, "to_port": 8002, "description": ""},
+	}
+
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("Expected rules %v, got %v", want, rules)
+	}
+
+	if rules := ingressRuleObjects(nil); len(rules) != 0 {
+		t.Errorf("Expected no rules without open ports, got %v", rules)
+	}
+}
@@ -0,0 +1,55 @@
+package provider
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	scanner "github.com/picatz/terraform-provider-port-scan/internal/provider/port-scanner"
+)
+
+// scanID returns an ID derived from what is scanned and how, so it stays the
+// same across runs of the same scan. The order of the ports doesn't matter.
+func scanID(ipAddresses []string, protocol string, ports []int, dialer string) string {
+	sorted := append([]int{}, ports...)
+	sort.Ints(sorted)
+
+	portStrings := make([]string, len(sorted))
+	for i, port := range sorted {
+		portStrings[i] = fmt.Sprint(port)
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		strings.Join(ipAddresses, ","),
+		protocol,
+		strings.Join(portStrings, ","),
+		dialer,
+	}, "\n")))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// setScanMetadata sets the attributes describing when and how the scan was
+// performed. Every dial counts as a probe, including retries, confirmations and
+// the connections of the probers.
+func setScanMetadata(d *schema.ResourceData, start time.Time, duration time.Duration, results []scanner.PortScanResult) error {
+	probes := 0
+	for _, result := range results {
+		probes += result.Attempts + result.ProberDials
+	}
+
+	if err := d.Set("scanned_at", start.UTC().Format(time.RFC3339)); err != nil {
+		return err
+	}
+	if err := d.Set("duration_ms", int(duration/time.Millisecond)); err != nil {
+		return err
+	}
+	if err := d.Set("probes_sent", probes); err != nil {
+		return err
+	}
+	return d.Set("dialer", dialerType(d))
+}
@@ -0,0 +1,21 @@
+package provider
+
+import "testing"
+
+func Test_scanID(t *testing.T) {
+	id := scanID([]string{"192.168.1.1"}, "tcp", []int{443, 22, 80}, dialerDirect)
+
+	if other := scanID([]string{"192.168.1.1"}, "tcp", []int{22, 80, 443}, dialerDirect); other != id {
+		t.Errorf("Expected the ID to not depend on the order of the ports, got %q and %q", id, other)
+	}
+
+	for _, other := range []string{
+		scanID([]string{"192.168.1.2"}, "tcp", []int{22, 80, 443}, dialerDirect),
+		scanID([]string{"192.168.1.1"}, "tcp", []int{22, 80}, dialerDirect),
+		scanID([]string{"192.168.1.1"}, "tcp", []int{22, 80, 443}, "ssh_bastion:root@10.0.0.1:22"),
+	} {
+		if other == id {
+			t.Errorf("Expected a different scan to have a different ID than %q", id)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package provider
+
+import (
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
+)
+
+// rateLimitSchema is the optional connection rate limit of a single scan,
+// applied on top of the provider's rate_limit.
+func rateLimitSchema() *schema.Schema {
+	return &schema.Schema{
+		ForceNew:     true,
+		Optional:     true,
+		Type:         schema.TypeFloat,
+		Default:      0,
+		ValidateFunc: validation.FloatAtLeast(0),
+		Description:  "Maximum connections per second for this scan, 0 is unlimited",
+	}
+}
+
+// hostRateLimitSchema is the optional connection rate limit of a single scan
+// to each host, applied on top of the provider's host_rate_limit.
+func hostRateLimitSchema() *schema.Schema {
+	return &schema.Schema{
+		ForceNew:     true,
+		Optional:     true,
+		Type:         schema.TypeFloat,
+		Default:      0,
+		ValidateFunc: validation.FloatAtLeast(0),
+		Description:  "Maximum connections per second to each target host for this scan, 0 is unlimited",
+	}
+}
+
+// jitterSchema is the optional random delay added before each probe.
+func jitterSchema() *schema.Schema {
+	return &schema.Schema{
+		ForceNew:     true,
+		Optional:     true,
+		Type:         schema.TypeString,
+		ValidateFunc: validateDuration,
+		Description:  "Upper bound of a random delay added before each probe, such as \"50ms\"",
+	}
+}
+
+// randomizePortsSchema shuffles the order in which the ports are scanned.
+func randomizePortsSchema() *schema.Schema {
+	return &schema.Schema{
+		ForceNew:    true,
+		Optional:    true,
+		Type:        schema.TypeBool,
+		Default:     false,
+		Description: "Scan ports in a random order",
+	}
+}
@@ -0,0 +1,159 @@
+package provider
+
+import (
+	"fmt"
+	"net"
+
+	scanner "github.com/picatz/terraform-provider-port-scan/internal/provider/port-scanner"
+)
+
+// Types of policy violations.
+const (
+	violationForbidden  = "forbidden"
+	violationNotAllowed = "not_allowed"
+	violationMissing    = "missing"
+)
+
+// policyHost is a scanned host and its tags.
+type policyHost struct {
+	ip   string
+	tags map[string]bool
+}
+
+// policyRule is a rule of a port exposure policy.
+type policyRule struct {
+	name string
+	// cidrs and tags select the hosts the rule applies to, every host when
+	// both are empty
+	cidrs []*net.IPNet
+	tags  []string
+
+	allowed   []int
+	forbidden []int
+	required  []int
+}
+
+// policyViolation is a port of a host that doesn't follow a rule.
+type policyViolation struct {
+	ip      string
+	port    int
+	kind    string
+	message string
+}
+
+// policyResult is the evaluation of a rule.
+type policyResult struct {
+	rule       *policyRule
+	hosts      []string
+	violations []policyViolation
+}
+
+// passed returns true when the rule has no violations.
+func (r *policyResult) passed() bool {
+	return len(r.violations) == 0
+}
+
+// selects returns true when the rule applies to the host, which must be in
+// one of its CIDR blocks and have one of its tags, when they're set.
+func (r *policyRule) selects(host *policyHost) bool {
+	if len(r.cidrs) > 0 {
+		ip, in := net.ParseIP(host.ip), false
+		for _, cidr := range r.cidrs {
+			if cidr.Contains(ip) {
+				in = true
+				break
+			}
+		}
+		if !in {
+			return false
+		}
+	}
+
+	if len(r.tags) > 0 {
+		for _, tag := range r.tags {
+			if host.tags[tag] {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+// exposurePolicy returns the policy for the open ports of the rule. The
+// required ports are allowed, and when there are no allowed ports, any port
+// that isn't forbidden is.
+func (r *policyRule) exposurePolicy() *scanner.ExposurePolicy {
+	return &scanner.ExposurePolicy{
+		AllowedPorts:   uniquePorts(r.allowed, r.required),
+		ForbiddenPorts: r.forbidden,
+		AllowUnlisted:  len(r.allowed) == 0,
+	}
+}
+
+// evaluate checks the sorted scan results of the hosts the rule applies to.
+func (r *policyRule) evaluate(hosts []*policyHost, results []scanner.PortScanResult) *policyResult {
+	var (
+		result = &policyResult{rule: r, hosts: []string{}, violations: []policyViolation{}}
+		policy = r.exposurePolicy()
+		byHost = map[string][]scanner.PortScanResult{}
+	)
+
+	for _, res := range results {
+		byHost[res.IP] = append(byHost[res.IP], res)
+	}
+
+	for _, host := range hosts {
+		if !r.selects(host) {
+			continue
+		}
+		result.hosts = append(result.hosts, host.ip)
+
+		open := map[int]bool{}
+		for _, exposure := range policy.Exposures(byHost[host.ip]) {
+			violation := policyViolation{ip: host.ip, port: exposure.Port, kind: violationNotAllowed}
+			if exposure.Forbidden {
+				violation.kind = violationForbidden
+			}
+			violation.message = fmt.Sprintf("port %d is open on %s, but is %s by rule %q", exposure.Port, host.ip, violationVerb(violation.kind), r.name)
+			result.violations = append(result.violations, violation)
+		}
+
+		for _, res := range byHost[host.ip] {
+			if res.Open {
+				open[res.Port] = true
+			}
+		}
+		for _, port := range r.required {
+			if !open[port] {
+				result.violations = append(result.violations, policyViolation{
+					ip:      host.ip,
+					port:    port,
+					kind:    violationMissing,
+					message: fmt.Sprintf("port %d is %s on %s, but is required to be open by rule %q", port, portState(byHost[host.ip], port), host.ip, r.name),
+				})
+			}
+		}
+	}
+
+	return result
+}
+
+// violationVerb describes why an open port violates a rule.
+func violationVerb(kind string) string {
+	if kind == violationForbidden {
+		return "forbidden"
+	}
+	return "not allowed"
+}
+
+// portState returns the state of the port in the results of a host.
+func portState(results []scanner.PortScanResult, port int) scanner.PortState {
+	for _, result := range results {
+		if result.Port == port {
+			return result.State
+		}
+	}
+	return scanner.PortStateFiltered
+}
@@ -0,0 +1,110 @@
+package provider
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	scanner "github.com/picatz/terraform-provider-port-scan/internal/provider/port-scanner"
+)
+
+func Test_policyRule_evaluate(t *testing.T) {
+	_, private, _ := net.ParseCIDR("10.0.0.0/24")
+
+	hosts := []*policyHost{
+		{ip: "10.0.0.1", tags: map[string]bool{"web": true}},
+		{ip: "10.0.0.2", tags: map[string]bool{"db": true}},
+		{ip: "10.0.1.1", tags: map[string]bool{"web": true}},
+	}
+
+	results := []scanner.PortScanResult{
+		{IP: "10.0.0.1", Port: 22, Open: true, State: scanner.PortStateOpen},
+		{IP: "10.0.0.1", Port: 23, Open: true, State: scanner.PortStateOpen},
+		{IP: "10.0.0.1", Port: 443, State: scanner.PortStateClosed},
+		{IP: "10.0.0.2", Port: 5432, Open: true, State: scanner.PortStateOpen},
+		{IP: "10.0.1.1", Port: 443, Open: true, State: scanner.PortStateOpen},
+	}
+
+	rule := &policyRule{
+		name:      "web",
+		cidrs:     []*net.IPNet{private},
+		tags:      []string{"web"},
+		allowed:   []int{22},
+		forbidden: []int{23},
+		required:  []int{443},
+	}
+
+	result := rule.evaluate(hosts, results)
+
+	if !reflect.DeepEqual(result.hosts, []string{"10.0.0.1"}) {
+		t.Errorf("Expected the rule to only select 10.0.0.1, got %v", result.hosts)
+	}
+
+	kinds := []string{}
+	for _, violation := range result.violations {
+		kinds = append(kinds, violation.kind)
+	}
+	if want := []string{violationForbidden, violationMissing}; !reflect.DeepEqual(kinds, want) {
+		t.Errorf("Expected violations %v, got %v", want, kinds)
+	}
+	if message := result.violations[1].message; message != `port 443 is closed on 10.0.0.1, but is required to be open by rule "web"` {
+		t.Errorf("Unexpected message %q", message)
+	}
+
+	// without allowed ports, only forbidden ports are violations
+	all := (&policyRule{name: "all", forbidden: []int{5432}}).evaluate(hosts, results)
+	if len(all.hosts) != 3 || len(all.violations) != 1 || all.violations[0].ip != "10.0.0.2" {
+		t.Errorf("Expected a single violation on 10.0.0.2, got %+v", all)
+	}
+
+	// open ports outside of the allowed ports are violations
+	strict := (&policyRule{name: "strict", tags: []string{"db"}, allowed: []int{443}}).evaluate(hosts, results)
+	if len(strict.violations) != 1 || strict.violations[0].kind != violationNotAllowed || strict.violations[0].port != 5432 {
+		t.Errorf("Expected port 5432 to not be allowed, got %+v", strict.violations)
+	}
+}
+
+func Test_dataSourcePortScanPolicyRead(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	config := map[string]interface{}{
+		"port": port,
+		"host": []interface{}{
+			map[string]interface{}{"address": "127.0.0.1", "tags": []interface{}{"local"}},
+		},
+		"rule": []interface{}{
+			map[string]interface{}{"name": "required", "tags": []interface{}{"local"}, "required_ports": []interface{}{port}},
+			map[string]interface{}{"name": "forbidden", "cidrs": []interface{}{"127.0.0.0/8"}, "forbidden_ports": []interface{}{port}},
+		},
+	}
+
+	d := schema.TestResourceDataRaw(t, dataSourcePortScanPolicy().Schema, config)
+	if err := dataSourcePortScanPolicyRead(d, &providerConfig{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if d.Get("passed").(bool) {
+		t.Error("Expected the policy to fail")
+	}
+	if !d.Get("rule.0.passed").(bool) || d.Get("rule.1.passed").(bool) {
+		t.Errorf("Expected only the forbidden rule to fail")
+	}
+	if kind := d.Get("rule.1.violations.0.type").(string); kind != violationForbidden {
+		t.Errorf("Expected a forbidden violation, got %q", kind)
+	}
+
+	config["fail_on_violation"] = true
+
+	d = schema.TestResourceDataRaw(t, dataSourcePortScanPolicy().Schema, config)
+	if err := dataSourcePortScanPolicyRead(d, &providerConfig{}); err == nil || !strings.Contains(err.Error(), `forbidden by rule "forbidden"`) {
+		t.Fatalf("Expected an error listing the violation, got %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// DefaultBannerTimeout is the default time to wait for a service to send its banner.
+var DefaultBannerTimeout = 2 * time.Second
+
+// maxBannerLength bounds how much of a banner is kept.
+const maxBannerLength = 256
+
+// readBanner returns the first line sent by the service on the connection
+// before its deadline, without non-printable characters. Services that wait
+// for the client to speak first, such as HTTP, don't have a banner.
+func readBanner(conn net.Conn) string {
+	line, err := bufio.NewReader(io.LimitReader(conn, maxBannerLength)).ReadString('\n')
+	if err != nil && line == "" {
+		return ""
+	}
+
+	return printable(line)
+}
+
+// printable trims the string and removes its non-printable characters.
+func printable(s string) string {
+	return strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsPrint(r) {
+			return r
+		}
+		return -1
+	}, s))
+}
+
+// BannerProber reads the banner sent by the service, which it reports as its
+// "banner" finding. Nothing is found when the service doesn't speak first.
+type BannerProber struct{}
+
+// Name returns "banner".
+func (BannerProber) Name() string {
+	return "banner"
+}
+
+// Probe reads the first line sent by the service.
+func (BannerProber) Probe(ctx context.Context, conn net.Conn, target Target) ([]Finding, error) {
+	banner := readBanner(conn)
+	if banner == "" {
+		return nil, nil
+	}
+	return []Finding{{Prober: "banner", Name: "banner", Value: banner}}, nil
+}
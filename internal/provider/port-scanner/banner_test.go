@@ -0,0 +1,52 @@
+package scanner
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func Test_RunWithOptions_grabBanners(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("SSH-2.0-Test_1.0\x00\r\nignored\r\n"))
+			conn.Close()
+		}
+	}()
+
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	for result := range RunWithOptions(DefaultDialer, "127.0.0.1", []int{port}, &Options{GrabBanners: true, BannerTimeout: time.Second}) {
+		if result.Banner != "SSH-2.0-Test_1.0" {
+			t.Errorf("Expected the first line of the banner, got %q", result.Banner)
+		}
+	}
+
+	for result := range RunWithOptions(DefaultDialer, "127.0.0.1", []int{port}, nil) {
+		if result.Banner != "" {
+			t.Errorf("Expected no banner unless enabled, got %q", result.Banner)
+		}
+	}
+}
+
+func Test_readBanner_silent(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	client.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+
+	if banner := readBanner(client); banner != "" {
+		t.Errorf("Expected no banner from a silent service, got %q", banner)
+	}
+}
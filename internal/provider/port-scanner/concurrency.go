@@ -0,0 +1,250 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// outcome classifies the result of a single dial for the concurrency controller.
+type outcome int
+
+const (
+	// outcomeOK is a healthy dial, either connected or actively refused.
+	outcomeOK outcome = iota
+	// outcomeTimeout is a dial that timed out.
+	outcomeTimeout
+	// outcomeExhausted is a dial that failed because the local host ran out
+	// of file descriptors, buffer space or ephemeral ports.
+	outcomeExhausted
+	// outcomeError is any other dial error, which is ignored by the controller.
+	outcomeError
+)
+
+const (
+	// minConcurrency is the floor the controller will never shrink below.
+	minConcurrency = 8
+	// minWindow is the minimum number of dials observed before the timeout
+	// ratio is evaluated.
+	minWindow = 32
+	// timeoutRatioThreshold is the ratio of timed out dials in a window above
+	// which a rising ratio causes the controller to back off.
+	timeoutRatioThreshold = 0.5
+	// decreaseCooldown prevents a burst of concurrent failures from
+	// collapsing the limit multiple times for what is a single event.
+	decreaseCooldown = 100 * time.Millisecond
+	// exhaustedBackoff is the base delay before re-dialing a port that failed
+	// due to local resource exhaustion.
+	exhaustedBackoff = 50 * time.Millisecond
+)
+
+// classifyError maps a dial error to an outcome.
+func classifyError(err error) outcome {
+	if err == nil {
+		return outcomeOK
+	}
+
+	switch {
+	case errors.Is(err, syscall.EMFILE),
+		errors.Is(err, syscall.ENFILE),
+		errors.Is(err, syscall.ENOBUFS),
+		errors.Is(err, syscall.EADDRNOTAVAIL),
+		strings.Contains(err.Error(), "too many open files"):
+		return outcomeExhausted
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return outcomeOK
+	case errors.Is(err, context.DeadlineExceeded):
+		return outcomeTimeout
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return outcomeTimeout
+	}
+
+	// SSH bastions report the remote dial error as a message on the
+	// rejected channel, like "Connection refused"
+	var chanErr *ssh.OpenChannelError
+	if errors.As(err, &chanErr) && chanErr.Reason == ssh.ConnectionFailed {
+		msg := strings.ToLower(chanErr.Message)
+		switch {
+		case strings.Contains(msg, "refused"):
+			return outcomeOK
+		case strings.Contains(msg, "timed out"):
+			return outcomeTimeout
+		}
+	}
+
+	return outcomeError
+}
+
+// concurrencyController limits the number of in-flight dials using an AIMD
+// (additive-increase, multiplicative-decrease) strategy, similar to TCP
+// congestion control. The limit is halved when the local host runs out of
+// resources or the ratio of timed out dials keeps rising, and slowly grows
+// back while dials are healthy.
+type concurrencyController struct {
+	mu sync.Mutex
+
+	limit    float64
+	ssthresh float64
+	min      int
+	max      int
+	inFlight int
+
+	windowTotal    int
+	windowTimeouts int
+	lastRatio      float64
+	lastDecrease   time.Time
+
+	// wake is closed (and replaced) every time a slot is released, waking
+	// up all goroutines waiting in Acquire.
+	wake chan struct{}
+}
+
+func newConcurrencyController(max int) *concurrencyController {
+	c := &concurrencyController{
+		wake: make(chan struct{}),
+	}
+	c.setMax(max)
+	return c
+}
+
+// controller is shared by all scans in the process, since they share the
+// same file descriptor limit.
+var controller = newConcurrencyController(ulimit())
+
+func (c *concurrencyController) setMax(max int) {
+	if max < 1 {
+		max = 1
+	}
+	c.max = max
+	c.min = minConcurrency
+	if c.min > c.max {
+		c.min = c.max
+	}
+	// until the controller has had to back off, start wide open
+	if c.lastDecrease.IsZero() || c.limit > float64(c.max) {
+		c.limit = float64(c.max)
+		c.ssthresh = float64(c.max)
+	}
+}
+
+// SetMax changes the upper bound for the number of in-flight dials.
+func (c *concurrencyController) SetMax(max int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setMax(max)
+	c.broadcast()
+}
+
+// Limit returns the current number of dials allowed to be in-flight.
+func (c *concurrencyController) Limit() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return int(c.limit)
+}
+
+// Acquire blocks until a dial slot is available, or the context is done.
+func (c *concurrencyController) Acquire(ctx context.Context) error {
+	for {
+		c.mu.Lock()
+		if c.inFlight < int(c.limit) {
+			c.inFlight++
+			c.mu.Unlock()
+			return nil
+		}
+		wake := c.wake
+		c.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-wake:
+		}
+	}
+}
+
+// Release returns a dial slot, adjusting the limit based on the outcome of the dial.
+func (c *concurrencyController) Release(o outcome) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.inFlight--
+
+	switch o {
+	case outcomeExhausted:
+		c.decrease()
+	case outcomeOK:
+		c.increase()
+		c.observe(false)
+	case outcomeTimeout:
+		c.observe(true)
+	}
+
+	c.broadcast()
+}
+
+func (c *concurrencyController) broadcast() {
+	close(c.wake)
+	c.wake = make(chan struct{})
+}
+
+// increase grows the limit by one per healthy dial below the slow start
+// threshold, and by roughly one per window of healthy dials above it.
+func (c *concurrencyController) increase() {
+	if c.limit < c.ssthresh {
+		c.limit++
+	} else {
+		c.limit += 1 / c.limit
+	}
+	if c.limit > float64(c.max) {
+		c.limit = float64(c.max)
+	}
+}
+
+// decrease halves the limit, unless it was already decreased very recently.
+func (c *concurrencyController) decrease() {
+	now := time.Now()
+	if now.Sub(c.lastDecrease) < decreaseCooldown {
+		return
+	}
+	c.lastDecrease = now
+
+	c.limit /= 2
+	if c.limit < float64(c.min) {
+		c.limit = float64(c.min)
+	}
+	c.ssthresh = c.limit
+}
+
+// observe records a dial in the current window, and backs off when the ratio
+// of timed out dials is high and rising compared to the previous window.
+func (c *concurrencyController) observe(timedOut bool) {
+	c.windowTotal++
+	if timedOut {
+		c.windowTimeouts++
+	}
+
+	size := int(c.limit)
+	if size < minWindow {
+		size = minWindow
+	}
+	if c.windowTotal < size {
+		return
+	}
+
+	ratio := float64(c.windowTimeouts) / float64(c.windowTotal)
+	if ratio >= timeoutRatioThreshold && ratio > c.lastRatio {
+		c.decrease()
+	}
+	c.lastRatio = ratio
+	c.windowTotal = 0
+	c.windowTimeouts = 0
+}
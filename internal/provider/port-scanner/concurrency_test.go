@@ -0,0 +1,108 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func Test_classifyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want outcome
+	}{
+		{nil, outcomeOK},
+		{&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, outcomeOK},
+		{&net.OpError{Op: "dial", Err: os.NewSyscallError("socket", syscall.EMFILE)}, outcomeExhausted},
+		{&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.EADDRNOTAVAIL)}, outcomeExhausted},
+		{fmt.Errorf("dial tcp: too many open files"), outcomeExhausted},
+		{context.DeadlineExceeded, outcomeTimeout},
+		{fmt.Errorf("ssh: rejected: connect failed"), outcomeError},
+		{&ssh.OpenChannelError{Reason: ssh.ConnectionFailed, Message: "Connection refused"}, outcomeOK},
+		{&ssh.OpenChannelError{Reason: ssh.ConnectionFailed, Message: "Connection timed out"}, outcomeTimeout},
+	}
+
+	for _, test := range tests {
+		if got := classifyError(test.err); got != test.want {
+			t.Errorf("classifyError(%v) = %d, want %d", test.err, got, test.want)
+		}
+	}
+}
+
+func Test_concurrencyController_exhausted(t *testing.T) {
+	c := newConcurrencyController(128)
+
+	if limit := c.Limit(); limit != 128 {
+		t.Fatalf("Expected initial limit of 128, got %d", limit)
+	}
+
+	c.Acquire(context.Background())
+	c.Release(outcomeExhausted)
+
+	if limit := c.Limit(); limit != 64 {
+		t.Fatalf("Expected limit to be halved to 64, got %d", limit)
+	}
+
+	// a burst of failures for the same event only backs off once
+	c.Acquire(context.Background())
+	c.Release(outcomeExhausted)
+
+	if limit := c.Limit(); limit != 64 {
+		t.Fatalf("Expected limit to stay at 64 during the cooldown, got %d", limit)
+	}
+
+	// healthy dials grow the limit back
+	for i := 0; i < 1000; i++ {
+		c.Acquire(context.Background())
+		c.Release(outcomeOK)
+	}
+
+	if limit := c.Limit(); limit <= 64 {
+		t.Fatalf("Expected limit to grow back above 64, got %d", limit)
+	}
+}
+
+func Test_concurrencyController_risingTimeouts(t *testing.T) {
+	c := newConcurrencyController(minWindow)
+
+	for i := 0; i < minWindow; i++ {
+		c.Acquire(context.Background())
+		c.Release(outcomeTimeout)
+	}
+
+	if limit := c.Limit(); limit != minWindow/2 {
+		t.Fatalf("Expected limit to be halved to %d, got %d", minWindow/2, limit)
+	}
+
+	// a steady (not rising) timeout ratio does not keep shrinking the limit
+	time.Sleep(decreaseCooldown)
+	for i := 0; i < minWindow; i++ {
+		c.Acquire(context.Background())
+		c.Release(outcomeTimeout)
+	}
+
+	if limit := c.Limit(); limit != minWindow/2 {
+		t.Fatalf("Expected limit to stay at %d, got %d", minWindow/2, limit)
+	}
+}
+
+func Test_concurrencyController_acquireCanceled(t *testing.T) {
+	c := newConcurrencyController(1)
+
+	if err := c.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := c.Acquire(ctx); err == nil {
+		t.Fatal("Expected acquire to fail once the context is done")
+	}
+}
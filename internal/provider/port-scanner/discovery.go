@@ -0,0 +1,135 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Reasons a host is considered alive by Discover.
+const (
+	// ReasonICMPEchoReply means the host answered an ICMP echo request.
+	ReasonICMPEchoReply = "icmp-echo-reply"
+	// ReasonTCPSynAck means the host accepted a TCP connection.
+	ReasonTCPSynAck = "syn-ack"
+	// ReasonTCPReset means the host actively refused a TCP connection, which
+	// still proves that it is up.
+	ReasonTCPReset = "reset"
+)
+
+// DefaultDiscoveryPorts are the ports probed by Discover when none are given.
+var DefaultDiscoveryPorts = []int{22, 80, 443, 3389}
+
+// DiscoveryOptions configures Discover.
+type DiscoveryOptions struct {
+	// Options are used for the TCP probes, and the rate limits for ICMP echo requests.
+	Options
+	// Ports are the TCP ports probed on each host, defaults to DefaultDiscoveryPorts.
+	Ports []int
+	// ICMP sends an ICMP echo request to each host before the TCP probes, which
+	// requires raw sockets (root or CAP_NET_RAW on linux), see ICMPAvailable.
+	ICMP bool
+}
+
+// DiscoveryResult is the outcome of host discovery for a single host.
+type DiscoveryResult struct {
+	IP    string
+	Alive bool
+	// Reasons explain why the host is considered alive, such as
+	// "icmp-echo-reply" or "tcp/22 reset".
+	Reasons []string
+}
+
+// Discover sweeps the given IPs to find out which hosts are alive. Hosts that
+// reply to an ICMP echo request (when enabled) aren't probed any further, the
+// others are considered alive if any of the TCP ports is either open or
+// actively refused.
+func Discover(d Dialer, ips []string, opts *DiscoveryOptions) ([]DiscoveryResult, error) {
+	return DiscoverContext(context.Background(), d, ips, opts)
+}
+
+// DiscoverContext is like Discover, but stops once the context is done, in
+// which case the error of the context is returned instead of results missing
+// the hosts that weren't probed.
+func DiscoverContext(ctx context.Context, d Dialer, ips []string, opts *DiscoveryOptions) ([]DiscoveryResult, error) {
+	o := DiscoveryOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if len(o.Ports) == 0 {
+		o.Ports = DefaultDiscoveryPorts
+	}
+
+	reasons := map[string][]string{}
+
+	if o.ICMP {
+		s := newScan(d, &o.Options)
+
+		replied, err := s.echoSweep(ctx, ips)
+		if err != nil {
+			return nil, err
+		}
+		for ip := range replied {
+			reasons[ip] = append(reasons[ip], ReasonICMPEchoReply)
+		}
+	}
+
+	pending := []string{}
+	for _, ip := range ips {
+		if len(reasons[ip]) == 0 {
+			pending = append(pending, ip)
+		}
+	}
+
+	byHost := map[string][]PortScanResult{}
+	if len(pending) > 0 {
+		for result := range RunHostsContext(ctx, d, pending, o.Ports, &o.Options) {
+			byHost[result.IP] = append(byHost[result.IP], result)
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	results := make([]DiscoveryResult, 0, len(ips))
+	for _, ip := range ips {
+		probes := byHost[ip]
+		sort.Slice(probes, func(i, j int) bool { return probes[i].Port < probes[j].Port })
+
+		for _, probe := range probes {
+			switch probe.State {
+			case PortStateOpen:
+				reasons[ip] = append(reasons[ip], fmt.Sprintf("tcp/%d %s", probe.Port, ReasonTCPSynAck))
+			case PortStateClosed:
+				reasons[ip] = append(reasons[ip], fmt.Sprintf("tcp/%d %s", probe.Port, ReasonTCPReset))
+			}
+		}
+
+		results = append(results, DiscoveryResult{
+			IP:      ip,
+			Alive:   len(reasons[ip]) > 0,
+			Reasons: append([]string{}, reasons[ip]...),
+		})
+	}
+
+	return results, nil
+}
+
+// echoSweep sends an ICMP echo request to each of the IPs, returning the ones
+// that replied within the scan's timeout.
+func (s *scan) echoSweep(ctx context.Context, ips []string) (map[string]bool, error) {
+	sweep, err := newEchoSweep()
+	if err != nil {
+		return nil, err
+	}
+	defer sweep.Close()
+
+	for _, ip := range ips {
+		s.wait(ctx, ip)
+		sweep.Send(ip)
+	}
+
+	return sweep.Replies(time.Now().Add(s.opts.TimeoutPerPort)), nil
+}
@@ -0,0 +1,102 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_Discover(t *testing.T) {
+	open, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer open.Close()
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed.Close()
+
+	var (
+		openPort   = open.Addr().(*net.TCPAddr).Port
+		closedPort = closed.Addr().(*net.TCPAddr).Port
+	)
+
+	results, err := Discover(DefaultDialer, []string{"127.0.0.1"}, &DiscoveryOptions{
+		Options: Options{TimeoutPerPort: 200 * time.Millisecond},
+		Ports:   []int{closedPort, openPort},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 result, got %d", len(results))
+	}
+
+	want := []string{
+		fmt.Sprintf("tcp/%d %s", openPort, ReasonTCPSynAck),
+		fmt.Sprintf("tcp/%d %s", closedPort, ReasonTCPReset),
+	}
+	if openPort > closedPort {
+		want[0], want[1] = want[1], want[0]
+	}
+
+	if !results[0].Alive || !reflect.DeepEqual(results[0].Reasons, want) {
+		t.Errorf("Expected 127.0.0.1 to be alive with reasons %v, got %+v", want, results[0])
+	}
+}
+
+func Test_Discover_icmp(t *testing.T) {
+	if !ICMPAvailable() {
+		t.Skip("raw ICMP sockets are not available")
+	}
+
+	results, err := Discover(DefaultDialer, []string{"127.0.0.1"}, &DiscoveryOptions{
+		Options: Options{TimeoutPerPort: time.Second},
+		ICMP:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !results[0].Alive || !reflect.DeepEqual(results[0].Reasons, []string{ReasonICMPEchoReply}) {
+		t.Errorf("Expected 127.0.0.1 to reply to the echo request, got %+v", results[0])
+	}
+}
+
+func Test_Discover_notAlive(t *testing.T) {
+	d := &scriptedDialer{script: []error{errScriptedTimeout}}
+
+	results, err := Discover(d, []string{"192.0.2.1"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if results[0].Alive || len(results[0].Reasons) != 0 {
+		t.Errorf("Expected a host that never answers to not be alive, got %+v", results[0])
+	}
+}
+
+func Test_DiscoverContext_canceled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+
+	_, err := DiscoverContext(ctx, blackholeDialer{}, []string{"192.0.2.1", "192.0.2.2"}, &DiscoveryOptions{
+		Options: Options{TimeoutPerPort: 10 * time.Second},
+	})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("Expected the deadline to be exceeded, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected discovery to stop at the deadline, took %s", elapsed)
+	}
+}
@@ -0,0 +1,48 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// HTTPProber sends a HEAD request for "/" and reports the "status" of the
+// response, and its "server" and "location" headers when set.
+type HTTPProber struct{}
+
+// Name returns "http".
+func (HTTPProber) Name() string {
+	return "http"
+}
+
+// Probe sends the HEAD request.
+func (HTTPProber) Probe(ctx context.Context, conn net.Conn, target Target) ([]Finding, error) {
+	req, err := http.NewRequest(http.MethodHead, "http://"+target.Address()+"/", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "port-scan")
+	req.Close = true
+
+	if err := req.Write(conn); err != nil {
+		return nil, fmt.Errorf("writing request: %s", err)
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %s", err)
+	}
+	resp.Body.Close()
+
+	findings := []Finding{{Prober: "http", Name: "status", Value: resp.Status}}
+	for _, header := range []string{"Server", "Location"} {
+		if value := resp.Header.Get(header); value != "" {
+			findings = append(findings, Finding{Prober: "http", Name: strings.ToLower(header), Value: printable(value)})
+		}
+	}
+
+	return findings, nil
+}
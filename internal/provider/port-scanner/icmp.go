@@ -0,0 +1,206 @@
+package scanner
+
+import (
+	"bytes"
+	"crypto/rand"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// ICMP protocol numbers, as used by icmp.ParseMessage.
+const (
+	protocolICMP     = 1
+	protocolICMPIPv6 = 58
+)
+
+// ICMPAvailable reports whether raw ICMP sockets can be opened, which
+// requires root or CAP_NET_RAW on linux.
+func ICMPAvailable() bool {
+	conn, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// echoConn is a raw ICMP socket for a single address family.
+type echoConn struct {
+	conn     *icmp.PacketConn
+	protocol int
+	request  icmp.Type
+	reply    icmp.Type
+}
+
+// echoSweep sends ICMP echo requests to many hosts over shared raw sockets,
+// collecting the replies in the background. Replies are matched using the
+// identifier and a random token in the payload, since raw sockets receive
+// all of the ICMP messages sent to the local host.
+type echoSweep struct {
+	id    int
+	token []byte
+	seq   int
+
+	mu      sync.Mutex
+	hosts   map[string]string
+	replied map[string]bool
+
+	v4 *echoConn
+	v6 *echoConn
+	wg sync.WaitGroup
+}
+
+// newEchoSweep opens the IPv4 raw socket, which fails without the privileges
+// required for ICMP. The IPv6 socket is only opened once it's needed.
+func newEchoSweep() (*echoSweep, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+
+	e := &echoSweep{
+		id:      os.Getpid() & 0xffff,
+		token:   token,
+		hosts:   map[string]string{},
+		replied: map[string]bool{},
+	}
+
+	v4, err := e.listen("ip4:icmp", "0.0.0.0", protocolICMP, ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply)
+	if err != nil {
+		return nil, err
+	}
+	e.v4 = v4
+
+	return e, nil
+}
+
+// listen opens a raw ICMP socket, and starts reading the replies from it.
+func (e *echoSweep) listen(network, address string, protocol int, request, reply icmp.Type) (*echoConn, error) {
+	conn, err := icmp.ListenPacket(network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &echoConn{conn: conn, protocol: protocol, request: request, reply: reply}
+
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		e.read(c)
+	}()
+
+	return c, nil
+}
+
+// read records the hosts replying to the echo requests until the socket's
+// read deadline, or until it's closed.
+func (e *echoSweep) read(c *echoConn) {
+	buf := make([]byte, 1500)
+	for {
+		n, peer, err := c.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+
+		msg, err := icmp.ParseMessage(c.protocol, buf[:n])
+		if err != nil || msg.Type != c.reply {
+			continue
+		}
+
+		echo, ok := msg.Body.(*icmp.Echo)
+		if !ok || echo.ID != e.id || !bytes.Equal(echo.Data, e.token) {
+			continue
+		}
+
+		addr, ok := peer.(*net.IPAddr)
+		if !ok {
+			continue
+		}
+
+		e.mu.Lock()
+		if host, ok := e.hosts[addr.IP.String()]; ok {
+			e.replied[host] = true
+		}
+		e.mu.Unlock()
+	}
+}
+
+// Send sends an echo request to the host. Hosts that can't be resolved, or
+// can't be sent to, are silently skipped, as they won't reply anyway.
+func (e *echoSweep) Send(host string) {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		addr, err := net.ResolveIPAddr("ip", host)
+		if err != nil {
+			return
+		}
+		ip = addr.IP
+	}
+
+	c := e.v4
+	if ip.To4() == nil {
+		if e.v6 == nil {
+			v6, err := e.listen("ip6:ipv6-icmp", "::", protocolICMPIPv6, ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply)
+			if err != nil {
+				return
+			}
+			e.v6 = v6
+		}
+		c = e.v6
+	}
+
+	e.mu.Lock()
+	e.hosts[ip.String()] = host
+	e.seq++
+	seq := e.seq & 0xffff
+	e.mu.Unlock()
+
+	msg := icmp.Message{
+		Type: c.request,
+		Body: &icmp.Echo{ID: e.id, Seq: seq, Data: e.token},
+	}
+
+	b, err := msg.Marshal(nil)
+	if err != nil {
+		return
+	}
+
+	c.conn.WriteTo(b, &net.IPAddr{IP: ip})
+}
+
+// Replies waits until the deadline for the echo replies, returning the hosts
+// which replied.
+func (e *echoSweep) Replies(deadline time.Time) map[string]bool {
+	for _, c := range []*echoConn{e.v4, e.v6} {
+		if c != nil {
+			c.conn.SetReadDeadline(deadline)
+		}
+	}
+
+	e.wg.Wait()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	replied := map[string]bool{}
+	for host := range e.replied {
+		replied[host] = true
+	}
+	return replied
+}
+
+// Close closes the raw sockets.
+func (e *echoSweep) Close() error {
+	for _, c := range []*echoConn{e.v4, e.v6} {
+		if c != nil {
+			c.conn.Close()
+		}
+	}
+	return nil
+}
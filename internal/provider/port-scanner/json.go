@@ -0,0 +1,112 @@
+package scanner
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// jsonTCPInfo is the JSON representation of TCPInfo.
+type jsonTCPInfo struct {
+	RTTMs       float64 `json:"rtt_ms"`
+	RTTVarMs    float64 `json:"rtt_var_ms"`
+	Retransmits uint32  `json:"retransmits"`
+}
+
+// jsonResult is the JSON representation of PortScanResult.
+type jsonResult struct {
+	IP       string    `json:"ip"`
+	Port     int       `json:"port"`
+	Protocol string    `json:"protocol"`
+	State    PortState `json:"state"`
+	Service  string    `json:"service,omitempty"`
+	Attempts int       `json:"attempts"`
+	// ProberDials isn't counted in Attempts
+	ProberDials int          `json:"prober_dials,omitempty"`
+	LatencyMs   float64      `json:"latency_ms"`
+	TCPInfo     *jsonTCPInfo `json:"tcp_info,omitempty"`
+	Banner      string       `json:"banner,omitempty"`
+	Findings    []Finding    `json:"findings,omitempty"`
+	Error       string       `json:"error,omitempty"`
+}
+
+// milliseconds converts a duration to fractional milliseconds.
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// fromMilliseconds converts fractional milliseconds to a duration.
+func fromMilliseconds(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// MarshalJSON encodes the result with durations in milliseconds, and the
+// error as its message.
+func (r PortScanResult) MarshalJSON() ([]byte, error) {
+	result := jsonResult{
+		IP:          r.IP,
+		Port:        r.Port,
+		Protocol:    "tcp",
+		State:       r.State,
+		Service:     r.ServiceName(),
+		Attempts:    r.Attempts,
+		ProberDials: r.ProberDials,
+		LatencyMs:   milliseconds(r.Latency),
+		Banner:      r.Banner,
+		Findings:    r.Findings,
+	}
+
+	if r.TCPInfo != nil {
+		result.TCPInfo = &jsonTCPInfo{
+			RTTMs:       milliseconds(r.TCPInfo.RTT),
+			RTTVarMs:    milliseconds(r.TCPInfo.RTTVar),
+			Retransmits: r.TCPInfo.Retransmits,
+		}
+	}
+
+	if r.Error != nil {
+		result.Error = r.Error.Error()
+	}
+
+	return json.Marshal(result)
+}
+
+// UnmarshalJSON decodes a result encoded by MarshalJSON. Only the message of
+// the error is kept, so it can't be inspected with errors.Is or errors.As.
+func (r *PortScanResult) UnmarshalJSON(data []byte) error {
+	var result jsonResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return err
+	}
+
+	*r = PortScanResult{
+		IP:          result.IP,
+		Port:        result.Port,
+		Open:        result.State == PortStateOpen,
+		State:       result.State,
+		Attempts:    result.Attempts,
+		ProberDials: result.ProberDials,
+		Latency:     fromMilliseconds(result.LatencyMs),
+		Banner:      result.Banner,
+		Findings:    result.Findings,
+	}
+
+	// the well-known service name is always encoded, only keep detected ones
+	if result.Service != ServiceName(result.Port) {
+		r.Service = result.Service
+	}
+
+	if result.TCPInfo != nil {
+		r.TCPInfo = &TCPInfo{
+			RTT:         fromMilliseconds(result.TCPInfo.RTTMs),
+			RTTVar:      fromMilliseconds(result.TCPInfo.RTTVarMs),
+			Retransmits: result.TCPInfo.Retransmits,
+		}
+	}
+
+	if result.Error != "" {
+		r.Error = errors.New(result.Error)
+	}
+
+	return nil
+}
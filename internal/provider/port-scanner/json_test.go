@@ -0,0 +1,45 @@
+package scanner
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_PortScanResult_json(t *testing.T) {
+	results := []PortScanResult{
+		{
+			IP:       "192.168.1.1",
+			Port:     22,
+			Open:     true,
+			State:    PortStateOpen,
+			Attempts: 1,
+			Latency:  1500 * time.Microsecond,
+			TCPInfo:  &TCPInfo{RTT: time.Millisecond, RTTVar: 500 * time.Microsecond, Retransmits: 2},
+			Banner:   "SSH-2.0-OpenSSH_8.2",
+		},
+		{
+			IP:       "192.168.1.1",
+			Port:     81,
+			State:    PortStateFiltered,
+			Attempts: 3,
+			Error:    errors.New("i/o timeout"),
+		},
+	}
+
+	data, err := json.Marshal(results)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := []PortScanResult{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(results, decoded) {
+		t.Errorf("Expected %+v, got %+v", results, decoded)
+	}
+}
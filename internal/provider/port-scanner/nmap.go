@@ -0,0 +1,554 @@
+package scanner
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Reasons reported by nmap for the state of a port found with a TCP connect scan.
+const (
+	nmapReasonConnRefused = "conn-refused"
+	nmapReasonNoResponse  = "no-response"
+)
+
+// nmapMaxListedPorts is how many ports of the most common state are listed
+// before they're collapsed into a count, like nmap does for closed ports.
+const nmapMaxListedPorts = 25
+
+// nmapTimeFormat is the ctime format nmap uses for human readable times.
+const nmapTimeFormat = time.ANSIC
+
+type nmapRun struct {
+	XMLName          xml.Name     `xml:"nmaprun"`
+	Scanner          string       `xml:"scanner,attr"`
+	Args             string       `xml:"args,attr,omitempty"`
+	Start            int64        `xml:"start,attr"`
+	StartStr         string       `xml:"startstr,attr"`
+	Version          string       `xml:"version,attr"`
+	XMLOutputVersion string       `xml:"xmloutputversion,attr"`
+	ScanInfo         nmapScanInfo `xml:"scaninfo"`
+	Hosts            []nmapHost   `xml:"host"`
+	RunStats         nmapRunStats `xml:"runstats"`
+}
+
+type nmapScanInfo struct {
+	Type        string `xml:"type,attr"`
+	Protocol    string `xml:"protocol,attr"`
+	NumServices int    `xml:"numservices,attr"`
+	Services    string `xml:"services,attr"`
+}
+
+type nmapHost struct {
+	StartTime int64          `xml:"starttime,attr,omitempty"`
+	EndTime   int64          `xml:"endtime,attr,omitempty"`
+	Status    nmapStatus     `xml:"status"`
+	Addresses []nmapAddress  `xml:"address"`
+	Hostnames []nmapHostname `xml:"hostnames>hostname"`
+	Ports     *nmapPorts     `xml:"ports"`
+	Times     *nmapTimes     `xml:"times"`
+}
+
+type nmapStatus struct {
+	State  string `xml:"state,attr"`
+	Reason string `xml:"reason,attr"`
+}
+
+type nmapAddress struct {
+	Addr     string `xml:"addr,attr"`
+	AddrType string `xml:"addrtype,attr"`
+}
+
+type nmapHostname struct {
+	Name string `xml:"name,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type nmapPorts struct {
+	ExtraPorts []nmapExtraPorts `xml:"extraports"`
+	Ports      []nmapPort       `xml:"port"`
+}
+
+type nmapExtraPorts struct {
+	State        string             `xml:"state,attr"`
+	Count        int                `xml:"count,attr"`
+	ExtraReasons []nmapExtraReasons `xml:"extrareasons"`
+}
+
+type nmapExtraReasons struct {
+	Reason string `xml:"reason,attr"`
+	Count  int    `xml:"count,attr"`
+}
+
+type nmapPort struct {
+	Protocol string       `xml:"protocol,attr"`
+	PortID   int          `xml:"portid,attr"`
+	State    nmapState    `xml:"state"`
+	Service  *nmapService `xml:"service"`
+	Scripts  []nmapScript `xml:"script"`
+}
+
+type nmapState struct {
+	State  string `xml:"state,attr"`
+	Reason string `xml:"reason,attr"`
+}
+
+type nmapService struct {
+	Name   string `xml:"name,attr"`
+	Method string `xml:"method,attr"`
+	Conf   int    `xml:"conf,attr"`
+}
+
+type nmapScript struct {
+	ID     string `xml:"id,attr"`
+	Output string `xml:"output,attr"`
+}
+
+type nmapTimes struct {
+	SRTT   int64 `xml:"srtt,attr"`
+	RTTVar int64 `xml:"rttvar,attr"`
+	To     int64 `xml:"to,attr"`
+}
+
+type nmapRunStats struct {
+	Finished nmapFinished `xml:"finished"`
+	Hosts    nmapHostStat `xml:"hosts"`
+}
+
+type nmapFinished struct {
+	Time    int64  `xml:"time,attr"`
+	TimeStr string `xml:"timestr,attr"`
+	Elapsed string `xml:"elapsed,attr"`
+	Summary string `xml:"summary,attr"`
+	Exit    string `xml:"exit,attr"`
+}
+
+type nmapHostStat struct {
+	Up    int `xml:"up,attr"`
+	Down  int `xml:"down,attr"`
+	Total int `xml:"total,attr"`
+}
+
+// nmapReason returns the reason nmap gives for the state of a port.
+func nmapReason(result PortScanResult) string {
+	switch result.State {
+	case PortStateOpen:
+		return ReasonTCPSynAck
+	case PortStateClosed:
+		return nmapReasonConnRefused
+	default:
+		return nmapReasonNoResponse
+	}
+}
+
+// reportHost is the results for a single host of a report.
+type reportHost struct {
+	ip      string
+	results []PortScanResult
+}
+
+// up returns true when any port of the host answered.
+func (h *reportHost) up() bool {
+	for _, result := range h.results {
+		if result.State != PortStateFiltered {
+			return true
+		}
+	}
+	return false
+}
+
+// upReason returns the reason the host is considered up or down.
+func (h *reportHost) upReason() string {
+	for _, result := range h.results {
+		if result.State != PortStateFiltered {
+			return nmapReason(result)
+		}
+	}
+	return nmapReasonNoResponse
+}
+
+// ignoredState returns the most common state, when it has too many ports to
+// list, along with the number of ports in that state.
+func (h *reportHost) ignoredState() (PortState, int) {
+	counts := map[PortState]int{}
+	for _, result := range h.results {
+		counts[result.State]++
+	}
+
+	var (
+		ignored PortState
+		count   int
+	)
+	for _, state := range []PortState{PortStateClosed, PortStateFiltered} {
+		if counts[state] > count {
+			ignored, count = state, counts[state]
+		}
+	}
+
+	if count <= nmapMaxListedPorts {
+		return "", 0
+	}
+	return ignored, count
+}
+
+// times returns the smoothed round trip time and its variation, estimated
+// from the latency of the ports that answered, in microseconds.
+func (h *reportHost) times() *nmapTimes {
+	latencies := []time.Duration{}
+	for _, result := range h.results {
+		if result.State != PortStateFiltered && result.Latency > 0 {
+			latencies = append(latencies, result.Latency)
+		}
+	}
+	if len(latencies) == 0 {
+		return nil
+	}
+
+	var sum time.Duration
+	for _, latency := range latencies {
+		sum += latency
+	}
+	srtt := sum / time.Duration(len(latencies))
+
+	var deviation time.Duration
+	for _, latency := range latencies {
+		if latency > srtt {
+			deviation += latency - srtt
+		} else {
+			deviation += srtt - latency
+		}
+	}
+	rttvar := deviation / time.Duration(len(latencies))
+
+	// nmap never uses a timeout below 100ms
+	to := srtt + 4*rttvar
+	if to < 100*time.Millisecond {
+		to = 100 * time.Millisecond
+	}
+
+	return &nmapTimes{
+		SRTT:   int64(srtt / time.Microsecond),
+		RTTVar: int64(rttvar / time.Microsecond),
+		To:     int64(to / time.Microsecond),
+	}
+}
+
+// reportHosts groups the results of the report by host, in the order the
+// hosts first appear.
+func reportHosts(report *Report) []*reportHost {
+	var (
+		hosts  = []*reportHost{}
+		byHost = map[string]*reportHost{}
+	)
+
+	for _, result := range report.Results {
+		host, ok := byHost[result.IP]
+		if !ok {
+			host = &reportHost{ip: result.IP}
+			byHost[result.IP] = host
+			hosts = append(hosts, host)
+		}
+		host.results = append(host.results, result)
+	}
+
+	return hosts
+}
+
+// reportPorts returns the ports scanned across all of the hosts of the report.
+func reportPorts(report *Report) []int {
+	seen := map[int]bool{}
+	ports := []int{}
+	for _, result := range report.Results {
+		if !seen[result.Port] {
+			seen[result.Port] = true
+			ports = append(ports, result.Port)
+		}
+	}
+	return ports
+}
+
+// addressType returns the nmap address type of the IP address.
+func addressType(ip string) string {
+	if strings.Contains(ip, ":") {
+		return "ipv6"
+	}
+	return "ipv4"
+}
+
+// nmapSummary returns the summary nmap writes once a scan is done.
+func nmapSummary(report *Report, up, total int) string {
+	addresses := "IP addresses"
+	if total == 1 {
+		addresses = "IP address"
+	}
+	hosts := "hosts"
+	if up == 1 {
+		hosts = "host"
+	}
+
+	return fmt.Sprintf("%d %s (%d %s up) scanned in %.2f seconds", total, addresses, up, hosts, report.Duration.Seconds())
+}
+
+// WriteNmapXML writes the report in the XML format of nmap, as a TCP connect
+// scan. Like nmap, hosts where no port answered are reported as down without
+// their ports, and the ports of the most common state are only counted when
+// there are too many of them to list.
+func WriteNmapXML(w io.Writer, report *Report) error {
+	var (
+		start = report.StartedAt
+		end   = start.Add(report.Duration)
+		ports = reportPorts(report)
+		hosts = reportHosts(report)
+		up    = 0
+	)
+
+	run := nmapRun{
+		Scanner:          "port-scan",
+		Start:            start.Unix(),
+		StartStr:         start.Format(nmapTimeFormat),
+		Version:          "7.80",
+		XMLOutputVersion: "1.04",
+		ScanInfo: nmapScanInfo{
+			Type:        "connect",
+			Protocol:    "tcp",
+			NumServices: len(ports),
+			Services:    FormatPorts(ports),
+		},
+		Hosts: []nmapHost{},
+	}
+
+	for _, host := range hosts {
+		h := nmapHost{
+			StartTime: start.Unix(),
+			EndTime:   end.Unix(),
+			Status:    nmapStatus{State: "down", Reason: host.upReason()},
+			Addresses: []nmapAddress{{Addr: host.ip, AddrType: addressType(host.ip)}},
+		}
+
+		if host.up() {
+			up++
+			h.Status.State = "up"
+			h.Ports = &nmapPorts{}
+			h.Times = host.times()
+
+			ignored, count := host.ignoredState()
+			if count > 0 {
+				h.Ports.ExtraPorts = append(h.Ports.ExtraPorts, nmapExtraPorts{
+					State:        string(ignored),
+					Count:        count,
+					ExtraReasons: []nmapExtraReasons{{Reason: nmapReason(PortScanResult{State: ignored}), Count: count}},
+				})
+			}
+
+			for _, result := range host.results {
+				if result.State == ignored {
+					continue
+				}
+
+				port := nmapPort{
+					Protocol: "tcp",
+					PortID:   result.Port,
+					State:    nmapState{State: string(result.State), Reason: nmapReason(result)},
+				}
+				if name := result.ServiceName(); name != "" {
+					port.Service = &nmapService{Name: name, Method: "table", Conf: 3}
+				}
+				if result.Banner != "" {
+					port.Scripts = append(port.Scripts, nmapScript{ID: "banner", Output: result.Banner})
+				}
+
+				h.Ports.Ports = append(h.Ports.Ports, port)
+			}
+		}
+
+		run.Hosts = append(run.Hosts, h)
+	}
+
+	run.RunStats = nmapRunStats{
+		Finished: nmapFinished{
+			Time:    end.Unix(),
+			TimeStr: end.Format(nmapTimeFormat),
+			Elapsed: fmt.Sprintf("%.2f", report.Duration.Seconds()),
+			Summary: fmt.Sprintf("Nmap done at %s; %s", end.Format(nmapTimeFormat), nmapSummary(report, up, len(hosts))),
+			Exit:    "success",
+		},
+		Hosts: nmapHostStat{Up: up, Down: len(hosts) - up, Total: len(hosts)},
+	}
+
+	if _, err := io.WriteString(w, xml.Header+"<!DOCTYPE nmaprun>\n"); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(run); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// WriteNmapGrepable writes the report in the grepable format of nmap, with
+// a status line for each host and a ports line for each host that is up.
+func WriteNmapGrepable(w io.Writer, report *Report) error {
+	var (
+		start = report.StartedAt
+		end   = start.Add(report.Duration)
+		hosts = reportHosts(report)
+		up    = 0
+		lines = []string{
+			fmt.Sprintf("# Nmap 7.80 scan initiated %s as: port-scan -ports %s", start.Format(nmapTimeFormat), FormatPorts(reportPorts(report))),
+		}
+	)
+
+	for _, host := range hosts {
+		if !host.up() {
+			lines = append(lines, fmt.Sprintf("Host: %s ()\tStatus: Down", host.ip))
+			continue
+		}
+
+		up++
+		lines = append(lines, fmt.Sprintf("Host: %s ()\tStatus: Up", host.ip))
+
+		ignored, count := host.ignoredState()
+
+		ports := []string{}
+		for _, result := range host.results {
+			if result.State == ignored {
+				continue
+			}
+			// port/state/protocol/owner/service/rpc info/version/
+			ports = append(ports, fmt.Sprintf("%d/%s/tcp//%s///", result.Port, result.State, grepableField(result.ServiceName())))
+		}
+
+		line := fmt.Sprintf("Host: %s ()\tPorts: %s", host.ip, strings.Join(ports, ", "))
+		if count > 0 {
+			line += fmt.Sprintf("\tIgnored State: %s (%d)", ignored, count)
+		}
+		lines = append(lines, line)
+	}
+
+	lines = append(lines, fmt.Sprintf("# Nmap done at %s -- %s", end.Format(nmapTimeFormat), nmapSummary(report, up, len(hosts))))
+
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+// grepableField replaces the characters separating the fields of the
+// grepable format.
+func grepableField(s string) string {
+	return strings.NewReplacer("/", "|", ",", " ").Replace(s)
+}
+
+// NmapHost is a host read from an nmap XML report.
+type NmapHost struct {
+	// IP is the IPv4 or IPv6 address of the host.
+	IP string
+	// Hostnames are the names given to nmap for the host, or resolved by it.
+	Hostnames []string
+	// Up is true when nmap found the host to be up.
+	Up bool
+}
+
+// NmapReport is a report read from nmap XML output.
+type NmapReport struct {
+	Report
+	// Hosts are all of the hosts of the report, including the ones that are
+	// down, in the order nmap reported them.
+	Hosts []NmapHost
+}
+
+// nmapPortState converts the state of a port reported by nmap. Only ports
+// known to be open or closed keep their state, the uncertain states, such as
+// "open|filtered", are reported as filtered.
+func nmapPortState(state string) PortState {
+	switch state {
+	case "open":
+		return PortStateOpen
+	case "closed":
+		return PortStateClosed
+	default:
+		return PortStateFiltered
+	}
+}
+
+// ReadNmapXML reads an nmap XML report. Only TCP ports are read, and the
+// ports nmap only counted, such as the closed ports of large scans, aren't
+// part of the results.
+func ReadNmapXML(r io.Reader) (*NmapReport, error) {
+	run := nmapRun{}
+	if err := xml.NewDecoder(r).Decode(&run); err != nil {
+		return nil, fmt.Errorf("invalid nmap XML report: %s", err)
+	}
+
+	report := &NmapReport{
+		Report: Report{
+			StartedAt: time.Unix(run.Start, 0).UTC(),
+			Results:   []PortScanResult{},
+		},
+		Hosts: []NmapHost{},
+	}
+
+	// the run stats are missing from the reports of interrupted scans
+	if elapsed, err := strconv.ParseFloat(run.RunStats.Finished.Elapsed, 64); err == nil {
+		report.Duration = time.Duration(elapsed * float64(time.Second))
+	}
+
+	for _, h := range run.Hosts {
+		host := NmapHost{
+			Hostnames: []string{},
+			Up:        h.Status.State == "up",
+		}
+
+		for _, address := range h.Addresses {
+			if address.AddrType == "ipv4" || address.AddrType == "ipv6" {
+				host.IP = address.Addr
+				break
+			}
+		}
+		if host.IP == "" {
+			continue
+		}
+
+		for _, hostname := range h.Hostnames {
+			host.Hostnames = append(host.Hostnames, hostname.Name)
+		}
+
+		report.Hosts = append(report.Hosts, host)
+
+		if h.Ports == nil {
+			continue
+		}
+
+		for _, port := range h.Ports.Ports {
+			if port.Protocol != "tcp" {
+				continue
+			}
+
+			result := PortScanResult{
+				IP:    host.IP,
+				Port:  port.PortID,
+				State: nmapPortState(port.State.State),
+			}
+			result.Open = result.State == PortStateOpen
+
+			if port.Service != nil && port.Service.Name != ServiceName(port.PortID) {
+				result.Service = port.Service.Name
+			}
+
+			for _, script := range port.Scripts {
+				if script.ID == "banner" {
+					result.Banner = script.Output
+				}
+			}
+
+			report.Results = append(report.Results, result)
+		}
+	}
+
+	return report, nil
+}
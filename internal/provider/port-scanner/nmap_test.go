@@ -0,0 +1,198 @@
+package scanner
+
+import (
+	"bytes"
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func nmapTestReport() *Report {
+	report := &Report{
+		StartedAt: time.Date(2020, 7, 1, 12, 0, 0, 0, time.UTC),
+		Duration:  1500 * time.Millisecond,
+		Dialer:    "direct",
+		Results: []PortScanResult{
+			{IP: "192.168.1.1", Port: 22, Open: true, State: PortStateOpen, Latency: time.Millisecond, Banner: "SSH-2.0-OpenSSH_8.2"},
+			{IP: "192.168.1.1", Port: 80, State: PortStateClosed, Latency: 3 * time.Millisecond},
+		},
+	}
+
+	for port := 1000; port < 1030; port++ {
+		report.Results = append(report.Results, PortScanResult{IP: "192.168.1.1", Port: port, State: PortStateFiltered})
+	}
+
+	report.Results = append(report.Results, PortScanResult{IP: "192.168.1.2", Port: 22, State: PortStateFiltered})
+
+	return report
+}
+
+func Test_WriteNmapXML(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := WriteNmapXML(buf, nmapTestReport()); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(buf.String(), xml.Header+"<!DOCTYPE nmaprun>\n<nmaprun ") {
+		t.Fatalf("Expected an nmaprun document, got:\n%s", buf)
+	}
+
+	run := nmapRun{}
+	if err := xml.Unmarshal(buf.Bytes(), &run); err != nil {
+		t.Fatal(err)
+	}
+
+	if run.ScanInfo.Services != "22,80,1000-1029" || run.ScanInfo.NumServices != 32 {
+		t.Errorf("Unexpected scan info %+v", run.ScanInfo)
+	}
+	if len(run.Hosts) != 2 {
+		t.Fatalf("Expected 2 hosts, got %d", len(run.Hosts))
+	}
+
+	up := run.Hosts[0]
+	if up.Status.State != "up" || up.Addresses[0].Addr != "192.168.1.1" || up.Addresses[0].AddrType != "ipv4" {
+		t.Errorf("Unexpected host %+v", up)
+	}
+	if len(up.Ports.ExtraPorts) != 1 || up.Ports.ExtraPorts[0].State != "filtered" || up.Ports.ExtraPorts[0].Count != 30 {
+		t.Errorf("Expected the filtered ports to be counted, got %+v", up.Ports.ExtraPorts)
+	}
+	if len(up.Ports.Ports) != 2 {
+		t.Fatalf("Expected 2 listed ports, got %+v", up.Ports.Ports)
+	}
+
+	ssh := up.Ports.Ports[0]
+	if ssh.PortID != 22 || ssh.State.State != "open" || ssh.State.Reason != "syn-ack" || ssh.Service.Name != "ssh" {
+		t.Errorf("Unexpected port %+v", ssh)
+	}
+	if len(ssh.Scripts) != 1 || ssh.Scripts[0].Output != "SSH-2.0-OpenSSH_8.2" {
+		t.Errorf("Expected the banner, got %+v", ssh.Scripts)
+	}
+	if up.Ports.Ports[1].State.Reason != "conn-refused" {
+		t.Errorf("Unexpected port %+v", up.Ports.Ports[1])
+	}
+	if up.Times == nil || up.Times.SRTT != 2000 || up.Times.RTTVar != 1000 {
+		t.Errorf("Unexpected times %+v", up.Times)
+	}
+
+	down := run.Hosts[1]
+	if down.Status.State != "down" || down.Ports != nil {
+		t.Errorf("Expected the host to be down without ports, got %+v", down)
+	}
+
+	if run.RunStats.Hosts != (nmapHostStat{Up: 1, Down: 1, Total: 2}) || run.RunStats.Finished.Elapsed != "1.50" {
+		t.Errorf("Unexpected run stats %+v", run.RunStats)
+	}
+}
+
+func Test_WriteNmapGrepable(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := WriteNmapGrepable(buf, nmapTestReport()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# Nmap 7.80 scan initiated Wed Jul  1 12:00:00 2020 as: port-scan -ports 22,80,1000-1029
+Host: 192.168.1.1 ()	Status: Up
+Host: 192.168.1.1 ()	Ports: 22/open/tcp//ssh///, 80/closed/tcp//http///	Ignored State: filtered (30)
+Host: 192.168.1.2 ()	Status: Down
+# Nmap done at Wed Jul  1 12:00:01 2020 -- 2 IP addresses (1 host up) scanned in 1.50 seconds
+`
+	if buf.String() != want {
+		t.Errorf("Expected:\n%s\ngot:\n%s", want, buf)
+	}
+}
+
+// nmapSample is trimmed output of "nmap -sS -sU -p T:22,80,443,8080,U:53 -oX - scanme.nmap.org 192.0.2.1".
+const nmapSample = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE nmaprun>
+<nmaprun scanner="nmap" args="nmap -sS -sU -p T:22,80,443,8080,U:53 -oX - scanme.nmap.org 192.0.2.1" start="1593604800" startstr="Wed Jul  1 12:00:00 2020" version="7.80" xmloutputversion="1.04">
+<scaninfo type="syn" protocol="tcp" numservices="4" services="22,80,443,8080"/>
+<scaninfo type="udp" protocol="udp" numservices="1" services="53"/>
+<host starttime="1593604800" endtime="1593604803"><status state="up" reason="echo-reply" reason_ttl="53"/>
+<address addr="45.33.32.156" addrtype="ipv4"/>
+<address addr="00:11:22:33:44:55" addrtype="mac"/>
+<hostnames>
+<hostname name="scanme.nmap.org" type="user"/>
+<hostname name="scanme.nmap.org" type="PTR"/>
+</hostnames>
+<ports><port protocol="udp" portid="53"><state state="open|filtered" reason="no-response" reason_ttl="0"/><service name="domain" method="table" conf="3"/></port>
+<port protocol="tcp" portid="22"><state state="open" reason="syn-ack" reason_ttl="53"/><service name="ssh" product="OpenSSH" version="6.6.1p1 Ubuntu 2ubuntu2.13" method="probed" conf="10"/><script id="banner" output="SSH-2.0-OpenSSH_6.6.1p1 Ubuntu-2ubuntu2.13"/></port>
+<port protocol="tcp" portid="80"><state state="open" reason="syn-ack" reason_ttl="53"/><service name="http" method="table" conf="3"/></port>
+<port protocol="tcp" portid="443"><state state="closed" reason="reset" reason_ttl="53"/><service name="https" method="table" conf="3"/></port>
+<port protocol="tcp" portid="8080"><state state="filtered" reason="no-response" reason_ttl="0"/><service name="http-proxy" method="table" conf="3"/></port>
+</ports>
+<times srtt="70123" rttvar="3000" to="100000"/>
+</host>
+<host><status state="down" reason="no-response" reason_ttl="0"/>
+<address addr="192.0.2.1" addrtype="ipv4"/>
+</host>
+<runstats><finished time="1593604803" timestr="Wed Jul  1 12:00:03 2020" elapsed="3.21" summary="Nmap done at Wed Jul  1 12:00:03 2020; 2 IP addresses (1 host up) scanned in 3.21 seconds" exit="success"/><hosts up="1" down="1" total="2"/>
+</runstats>
+</nmaprun>
+`
+
+func Test_ReadNmapXML(t *testing.T) {
+	report, err := ReadNmapXML(strings.NewReader(nmapSample))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !report.StartedAt.Equal(time.Date(2020, 7, 1, 12, 0, 0, 0, time.UTC)) || report.Duration != 3210*time.Millisecond {
+		t.Errorf("Unexpected start %s and duration %s", report.StartedAt, report.Duration)
+	}
+
+	wantHosts := []NmapHost{
+		{IP: "45.33.32.156", Hostnames: []string{"scanme.nmap.org", "scanme.nmap.org"}, Up: true},
+		{IP: "192.0.2.1", Hostnames: []string{}},
+	}
+	if !reflect.DeepEqual(report.Hosts, wantHosts) {
+		t.Errorf("Expected hosts %+v, got %+v", wantHosts, report.Hosts)
+	}
+
+	wantResults := []PortScanResult{
+		{IP: "45.33.32.156", Port: 22, Open: true, State: PortStateOpen, Banner: "SSH-2.0-OpenSSH_6.6.1p1 Ubuntu-2ubuntu2.13"},
+		{IP: "45.33.32.156", Port: 80, Open: true, State: PortStateOpen},
+		{IP: "45.33.32.156", Port: 443, State: PortStateClosed},
+		{IP: "45.33.32.156", Port: 8080, State: PortStateFiltered, Service: "http-proxy"},
+	}
+	if !reflect.DeepEqual(report.Results, wantResults) {
+		t.Errorf("Expected results %+v, got %+v", wantResults, report.Results)
+	}
+
+	if name := report.Results[3].ServiceName(); name != "http-proxy" {
+		t.Errorf("Expected the service detected by nmap, got %q", name)
+	}
+}
+
+func Test_ReadNmapXML_written(t *testing.T) {
+	written := nmapTestReport()
+
+	buf := &bytes.Buffer{}
+	if err := WriteNmapXML(buf, written); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := ReadNmapXML(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the filtered ports are only counted, and the latencies aren't kept
+	want := []PortScanResult{
+		{IP: "192.168.1.1", Port: 22, Open: true, State: PortStateOpen, Banner: "SSH-2.0-OpenSSH_8.2"},
+		{IP: "192.168.1.1", Port: 80, State: PortStateClosed},
+	}
+	if !reflect.DeepEqual(report.Results, want) {
+		t.Errorf("Expected %+v, got %+v", want, report.Results)
+	}
+	if len(report.Hosts) != 2 || report.Hosts[1].Up {
+		t.Errorf("Unexpected hosts %+v", report.Hosts)
+	}
+}
+
+func Test_ReadNmapXML_invalid(t *testing.T) {
+	if _, err := ReadNmapXML(strings.NewReader("Host: 192.168.1.1 ()\tStatus: Up")); err == nil {
+		t.Fatal("Expected an error for a report that isn't XML")
+	}
+}
@@ -0,0 +1,103 @@
+package scanner
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParsePorts parses a comma separated list of ports and inclusive port
+// ranges, such as "22,80,8000-8100", into the list of ports it describes,
+// keeping the given order and removing duplicates.
+func ParsePorts(spec string) ([]int, error) {
+	var (
+		seen  = map[int]bool{}
+		ports = []int{}
+	)
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		first, last := part, part
+		if i := strings.Index(part, "-"); i >= 0 {
+			first, last = part[:i], part[i+1:]
+		}
+
+		firstPort, err := parsePort(first)
+		if err != nil {
+			return nil, err
+		}
+		lastPort, err := parsePort(last)
+		if err != nil {
+			return nil, err
+		}
+		if firstPort > lastPort {
+			return nil, fmt.Errorf("invalid port range %q, %d is greater than %d", part, firstPort, lastPort)
+		}
+
+		for _, port := range PortRange(firstPort, lastPort) {
+			if !seen[port] {
+				seen[port] = true
+				ports = append(ports, port)
+			}
+		}
+	}
+
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("no ports in %q", spec)
+	}
+
+	return ports, nil
+}
+
+// parsePort parses a single port number between 1 and 65535.
+func parsePort(s string) (int, error) {
+	port, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("invalid port %q, expected a number between 1 and 65535", s)
+	}
+	return port, nil
+}
+
+// PortSpan is an inclusive range of ports.
+type PortSpan struct {
+	From int
+	To   int
+}
+
+// CollapsePorts collapses the ports into the fewest sorted contiguous spans.
+func CollapsePorts(ports []int) []PortSpan {
+	sorted := append([]int{}, ports...)
+	sort.Ints(sorted)
+
+	spans := []PortSpan{}
+	for _, port := range sorted {
+		if n := len(spans); n > 0 && port <= spans[n-1].To+1 {
+			if port > spans[n-1].To {
+				spans[n-1].To = port
+			}
+			continue
+		}
+		spans = append(spans, PortSpan{From: port, To: port})
+	}
+
+	return spans
+}
+
+// FormatPorts formats the ports as the shortest spec ParsePorts accepts,
+// such as "22,80,8000-8100".
+func FormatPorts(ports []int) string {
+	parts := []string{}
+	for _, span := range CollapsePorts(ports) {
+		if span.From == span.To {
+			parts = append(parts, strconv.Itoa(span.From))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", span.From, span.To))
+		}
+	}
+	return strings.Join(parts, ",")
+}
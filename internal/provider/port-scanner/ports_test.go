@@ -0,0 +1,36 @@
+package scanner
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_ParsePorts(t *testing.T) {
+	ports, err := ParsePorts("443, 20-22,80,21")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []int{443, 20, 21, 22, 80}; !reflect.DeepEqual(ports, want) {
+		t.Errorf("Expected %v, got %v", want, ports)
+	}
+
+	for _, spec := range []string{"", "0", "65536", "22-20", "http", "1-"} {
+		if _, err := ParsePorts(spec); err == nil {
+			t.Errorf("Expected %q to be invalid", spec)
+		}
+	}
+}
+
+func Test_CollapsePorts(t *testing.T) {
+	spans := CollapsePorts([]int{8001, 22, 80, 8000, 443, 8002, 22})
+
+	want := []PortSpan{{22, 22}, {80, 80}, {443, 443}, {8000, 8002}}
+	if !reflect.DeepEqual(spans, want) {
+		t.Errorf("Expected %v, got %v", want, spans)
+	}
+
+	if spec := FormatPorts([]int{8001, 22, 80, 8000, 443, 8002}); spec != "22,80,443,8000-8002" {
+		t.Errorf("Expected \"22,80,443,8000-8002\", got %q", spec)
+	}
+}
@@ -0,0 +1,235 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Target describes the open port a Prober is run against.
+type Target struct {
+	// IP is the address of the scanned host.
+	IP string
+	// Port is the open TCP port.
+	Port int
+	// Service is the name of the service usually listening on the port,
+	// empty if it isn't a well-known port.
+	Service string
+}
+
+// Address returns the "ip:port" address of the target.
+func (t Target) Address() string {
+	return net.JoinHostPort(t.IP, strconv.Itoa(t.Port))
+}
+
+// Finding is a fact a Prober learned about the service on an open port,
+// such as the TLS version it negotiated.
+type Finding struct {
+	// Prober is the name of the prober that found it.
+	Prober string `json:"prober"`
+	// Name identifies the finding within the prober, such as "version".
+	Name string `json:"name"`
+	// Value is the finding itself, such as "TLS 1.3".
+	Value string `json:"value"`
+}
+
+// Prober runs an application-level check against an open port.
+type Prober interface {
+	// Name identifies the prober, such as "tls".
+	Name() string
+	// Probe checks the service on the connection, which is closed by the
+	// caller once it returns. The deadline of the connection is set to the
+	// deadline of the context, or when the connection doesn't support
+	// deadlines, such as through an SSH bastion, it's closed once the context
+	// is done, failing any read or write in progress.
+	Probe(ctx context.Context, conn net.Conn, target Target) ([]Finding, error)
+}
+
+// registeredProber is a prober and the ports it's run on.
+type registeredProber struct {
+	prober   Prober
+	ports    map[int]bool
+	services map[string]bool
+}
+
+// runsOn returns true when the prober is registered for the target, or for
+// every port.
+func (r *registeredProber) runsOn(target Target) bool {
+	if len(r.ports) == 0 && len(r.services) == 0 {
+		return true
+	}
+	return r.ports[target.Port] || (target.Service != "" && r.services[target.Service])
+}
+
+// Registry holds the probers that can be run on open ports, keyed by the
+// ports and services they apply to.
+type Registry struct {
+	mu      sync.RWMutex
+	probers []*registeredProber
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds the prober to the registry, to be run on the given ports and
+// on the ports whose well-known service is one of the given services. Probers
+// registered without ports or services are run on every open port. Probers
+// are run in the order they were registered.
+func (r *Registry) Register(p Prober, ports []int, services []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, registered := range r.probers {
+		if registered.prober.Name() == p.Name() {
+			return fmt.Errorf("prober %q is already registered", p.Name())
+		}
+	}
+
+	registered := &registeredProber{prober: p, ports: map[int]bool{}, services: map[string]bool{}}
+	for _, port := range ports {
+		registered.ports[port] = true
+	}
+	for _, service := range services {
+		registered.services[service] = true
+	}
+
+	r.probers = append(r.probers, registered)
+	return nil
+}
+
+// Names returns the names of the registered probers, sorted.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := []string{}
+	for _, registered := range r.probers {
+		names = append(names, registered.prober.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Probers returns the probers with the given names that are registered for
+// the target, in the order they were registered.
+func (r *Registry) Probers(target Target, names []string) []Prober {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	selected := map[string]bool{}
+	for _, name := range names {
+		selected[name] = true
+	}
+
+	probers := []Prober{}
+	for _, registered := range r.probers {
+		if selected[registered.prober.Name()] && registered.runsOn(target) {
+			probers = append(probers, registered.prober)
+		}
+	}
+	return probers
+}
+
+// DefaultRegistry holds the built-in probers:
+//
+// * "banner" reads the first line sent by the service, on every port.
+// * "tls" inspects the negotiated TLS version, cipher suite and certificate, on TLS ports.
+// * "http" reads the status and server of the response to a HEAD request, on HTTP ports.
+var DefaultRegistry = NewRegistry()
+
+func init() {
+	DefaultRegistry.Register(BannerProber{}, nil, nil)
+	DefaultRegistry.Register(TLSProber{}, nil, []string{"https", "https-alt", "smtps", "ldaps", "imaps", "pop3s", "docker-s", "kubernetes-api"})
+	DefaultRegistry.Register(HTTPProber{}, nil, []string{"http", "http-alt"})
+}
+
+// runProbers runs the selected probers of the scan against the port, when it's
+// open, setting the findings and banner of the result. Probers usually consume
+// the connection they're given, so each of them gets its own connection,
+// dialed like any other probe: after the rate limits and jitter, and while
+// holding a slot from the concurrency controller. Those dials are counted in
+// the ProberDials of the result, so Attempts doesn't depend on the selected
+// probers. A prober's error is reported as its "error" finding.
+func (s *scan) runProbers(result *PortScanResult) {
+	if !result.Open {
+		return
+	}
+
+	target := Target{IP: result.IP, Port: result.Port, Service: ServiceName(result.Port)}
+
+	probers := s.opts.Registry.Probers(target, s.opts.Probers)
+	if len(probers) == 0 {
+		return
+	}
+
+	findings := []Finding{}
+	for _, prober := range probers {
+		s.wait(s.ctx, result.IP)
+		if controller.Acquire(s.ctx) != nil {
+			break
+		}
+
+		conn, dialed := dialPort(s.ctx, s.dialer, result.IP, result.Port, s.timeout(result.IP))
+
+		outcome := classifyError(dialed.Error)
+		if outcome == outcomeOK {
+			s.rtts.Get(result.IP).Observe(dialed.Latency)
+		}
+		if outcome != outcomeExhausted {
+			result.ProberDials++
+		}
+
+		if conn == nil {
+			controller.Release(outcome)
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(s.ctx, s.opts.ProbeTimeout)
+		stop := closeWhenDone(ctx, conn)
+
+		proberFindings, err := prober.Probe(ctx, conn, target)
+		stop()
+		cancel()
+		conn.Close()
+		controller.Release(outcome)
+
+		findings = append(findings, proberFindings...)
+		if err != nil {
+			findings = append(findings, Finding{Prober: prober.Name(), Name: "error", Value: err.Error()})
+		}
+	}
+
+	result.Findings = findings
+	for _, finding := range findings {
+		if finding.Prober == "banner" && finding.Name == "banner" {
+			result.Banner = finding.Value
+		}
+	}
+}
+
+// closeWhenDone sets the deadline of the connection to the deadline of the
+// context. Connections that don't support deadlines, such as the channels of
+// an SSH bastion, are closed once the context is done instead, so a service
+// that never answers can't block the prober. The returned function must be
+// called once the connection isn't used anymore.
+func closeWhenDone(ctx context.Context, conn net.Conn) (stop func()) {
+	deadline, _ := ctx.Deadline()
+	if conn.SetDeadline(deadline) == nil {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
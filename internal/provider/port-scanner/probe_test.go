@@ -0,0 +1,211 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// echoProber reports the target it was run against.
+type echoProber struct{ name string }
+
+func (p echoProber) Name() string {
+	return p.name
+}
+
+func (p echoProber) Probe(ctx context.Context, conn net.Conn, target Target) ([]Finding, error) {
+	if p.name == "failing" {
+		return nil, fmt.Errorf("failed")
+	}
+	return []Finding{{Prober: p.name, Name: "target", Value: target.Address()}}, nil
+}
+
+func Test_Registry(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register(echoProber{"any"}, nil, nil)
+	registry.Register(echoProber{"ssh"}, nil, []string{"ssh"})
+	registry.Register(echoProber{"port"}, []int{2222}, nil)
+
+	if err := registry.Register(echoProber{"any"}, nil, nil); err == nil {
+		t.Errorf("Expected an error registering a prober twice")
+	}
+
+	if names := registry.Names(); !reflect.DeepEqual(names, []string{"any", "port", "ssh"}) {
+		t.Errorf("Expected the sorted prober names, got %v", names)
+	}
+
+	names := func(probers []Prober) []string {
+		names := []string{}
+		for _, prober := range probers {
+			names = append(names, prober.Name())
+		}
+		return names
+	}
+
+	all := []string{"any", "ssh", "port"}
+
+	if probers := names(registry.Probers(Target{Port: 22, Service: "ssh"}, all)); !reflect.DeepEqual(probers, []string{"any", "ssh"}) {
+		t.Errorf("Expected the probers of every port and of the ssh service, got %v", probers)
+	}
+	if probers := names(registry.Probers(Target{Port: 2222}, all)); !reflect.DeepEqual(probers, []string{"any", "port"}) {
+		t.Errorf("Expected the probers of every port and of port 2222, got %v", probers)
+	}
+	if probers := names(registry.Probers(Target{Port: 22, Service: "ssh"}, []string{"ssh"})); !reflect.DeepEqual(probers, []string{"ssh"}) {
+		t.Errorf("Expected only the selected probers, got %v", probers)
+	}
+}
+
+func Test_RunWithOptions_probers(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	registry := NewRegistry()
+	registry.Register(echoProber{"first"}, nil, nil)
+	registry.Register(echoProber{"failing"}, nil, nil)
+	registry.Register(echoProber{"unselected"}, nil, nil)
+
+	opts := &Options{Registry: registry, Probers: []string{"first", "failing"}}
+
+	for result := range RunWithOptions(DefaultDialer, "127.0.0.1", []int{port}, opts) {
+		want := []Finding{
+			{Prober: "first", Name: "target", Value: fmt.Sprintf("127.0.0.1:%d", port)},
+			{Prober: "failing", Name: "error", Value: "failed"},
+		}
+		if !reflect.DeepEqual(result.Findings, want) {
+			t.Errorf("Expected findings %v, got %v", want, result.Findings)
+		}
+	}
+}
+
+// probe runs the prober against a connection to the listener.
+func probe(t *testing.T, prober Prober, addr net.Addr) []Finding {
+	conn, err := net.Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Second))
+
+	tcpAddr := addr.(*net.TCPAddr)
+
+	findings, err := prober.Probe(context.Background(), conn, Target{IP: tcpAddr.IP.String(), Port: tcpAddr.Port})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return findings
+}
+
+// findingValues maps the names of the findings to their values.
+func findingValues(findings []Finding) map[string]string {
+	values := map[string]string{}
+	for _, finding := range findings {
+		values[finding.Name] = finding.Value
+	}
+	return values
+}
+
+func Test_TLSProber(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+
+	values := findingValues(probe(t, TLSProber{}, server.Listener.Addr()))
+
+	if !strings.HasPrefix(values["version"], "TLS 1.") {
+		t.Errorf("Expected the TLS version, got %q", values["version"])
+	}
+	if values["cipher_suite"] == "" {
+		t.Errorf("Expected the cipher suite")
+	}
+	if !strings.Contains(values["subject"], "Acme Co") {
+		t.Errorf("Expected the subject of the test certificate, got %q", values["subject"])
+	}
+	if !strings.Contains(values["dns_names"], "example.com") {
+		t.Errorf("Expected the DNS names of the test certificate, got %q", values["dns_names"])
+	}
+}
+
+func Test_HTTPProber(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "test/1.0")
+		http.Redirect(w, r, "/login", http.StatusFound)
+	}))
+	defer server.Close()
+
+	values := findingValues(probe(t, HTTPProber{}, server.Listener.Addr()))
+
+	want := map[string]string{"status": "302 Found", "server": "test/1.0", "location": "/login"}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("Expected findings %v, got %v", want, values)
+	}
+}
+
+// silentConn doesn't support deadlines, like the channels of an SSH bastion,
+// and never sends any data.
+type silentConn struct{ net.Conn }
+
+func (silentConn) SetDeadline(t time.Time) error {
+	return fmt.Errorf("deadlines not supported")
+}
+
+// silentDialer dials silentConns, keeping their peers open.
+type silentDialer struct{ peers []net.Conn }
+
+func (d *silentDialer) DialTimeout(network, address string, timeout time.Duration) (net.Conn, error) {
+	client, server := net.Pipe()
+	d.peers = append(d.peers, server)
+	return silentConn{client}, nil
+}
+
+func (d *silentDialer) Close() error {
+	for _, peer := range d.peers {
+		peer.Close()
+	}
+	return nil
+}
+
+func Test_scan_runProbers_withoutDeadlines(t *testing.T) {
+	d := &silentDialer{}
+	defer d.Close()
+
+	s := newScan(d, &Options{
+		Probers:      []string{"banner"},
+		ProbeTimeout: 50 * time.Millisecond,
+	})
+
+	done := make(chan PortScanResult)
+	go func() {
+		result := PortScanResult{IP: "192.0.2.1", Port: 22, Open: true}
+		s.runProbers(&result)
+		done <- result
+	}()
+
+	select {
+	case result := <-done:
+		if result.Banner != "" || result.ProberDials != 1 {
+			t.Errorf("Expected no banner after a single dial, got %q after %d dials", result.Banner, result.ProberDials)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the prober to stop once its timeout expired")
+	}
+}
@@ -0,0 +1,112 @@
+package scanner
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket limiting the number of connections per second.
+// A nil RateLimiter allows all connections.
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a RateLimiter allowing rate connections per second,
+// with bursts of up to burst connections.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// reserve takes a token from the bucket, returning how long the caller must
+// wait before the token may be used.
+func (l *RateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// Wait blocks until a connection is allowed, or the context is done.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l == nil || l.rate <= 0 {
+		return nil
+	}
+	return sleep(ctx, l.reserve())
+}
+
+// HostRateLimiter keeps a separate token bucket for every target host.
+// A nil HostRateLimiter allows all connections.
+type HostRateLimiter struct {
+	mu    sync.Mutex
+	rate  float64
+	burst int
+	hosts map[string]*RateLimiter
+}
+
+// NewHostRateLimiter creates a HostRateLimiter allowing rate connections per
+// second to each host, with bursts of up to burst connections.
+func NewHostRateLimiter(rate float64, burst int) *HostRateLimiter {
+	return &HostRateLimiter{
+		rate:  rate,
+		burst: burst,
+		hosts: map[string]*RateLimiter{},
+	}
+}
+
+// Wait blocks until a connection to the given host is allowed, or the context is done.
+func (h *HostRateLimiter) Wait(ctx context.Context, host string) error {
+	if h == nil || h.rate <= 0 {
+		return nil
+	}
+
+	h.mu.Lock()
+	limiter, ok := h.hosts[host]
+	if !ok {
+		limiter = NewRateLimiter(h.rate, h.burst)
+		h.hosts[host] = limiter
+	}
+	h.mu.Unlock()
+
+	return limiter.Wait(ctx)
+}
+
+// sleep pauses for the given duration, or until the context is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,53 @@
+package scanner
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_RateLimiter_Wait(t *testing.T) {
+	limiter := NewRateLimiter(100, 1)
+
+	start := time.Now()
+	for i := 0; i < 11; i++ {
+		if err := limiter.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the first connection uses the burst, the next 10 are spaced 10ms apart
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Fatalf("Expected rate limited connections to take at least 90ms, took %s", elapsed)
+	}
+}
+
+func Test_RateLimiter_nil(t *testing.T) {
+	var limiter *RateLimiter
+	if err := limiter.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_HostRateLimiter_Wait(t *testing.T) {
+	limiter := NewHostRateLimiter(10, 1)
+
+	start := time.Now()
+	for _, host := range []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"} {
+		if err := limiter.Wait(context.Background(), host); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// each host has its own bucket, so none of them should wait
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Fatalf("Expected connections to different hosts not to wait, took %s", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := limiter.Wait(ctx, "192.0.2.1"); err == nil {
+		t.Fatal("Expected a second connection to the same host to be limited")
+	}
+}
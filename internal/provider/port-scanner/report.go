@@ -0,0 +1,114 @@
+package scanner
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Report describes a complete scan, as written by the port-scan command and
+// read back as a baseline for comparisons.
+type Report struct {
+	// StartedAt is when the scan started.
+	StartedAt time.Time
+	// Duration is how long the scan took.
+	Duration time.Duration
+	// Dialer is either "direct" or "ssh_bastion".
+	Dialer string
+	// Results are the results for each scanned port, sorted by IP then port.
+	Results []PortScanResult
+}
+
+// jsonReport is the JSON representation of Report.
+type jsonReport struct {
+	StartedAt  time.Time        `json:"started_at"`
+	DurationMs float64          `json:"duration_ms"`
+	Dialer     string           `json:"dialer,omitempty"`
+	Results    []PortScanResult `json:"results"`
+}
+
+// MarshalJSON encodes the report with its duration in milliseconds.
+func (r Report) MarshalJSON() ([]byte, error) {
+	results := r.Results
+	if results == nil {
+		results = []PortScanResult{}
+	}
+
+	return json.Marshal(jsonReport{
+		StartedAt:  r.StartedAt,
+		DurationMs: milliseconds(r.Duration),
+		Dialer:     r.Dialer,
+		Results:    results,
+	})
+}
+
+// UnmarshalJSON decodes a report encoded by MarshalJSON.
+func (r *Report) UnmarshalJSON(data []byte) error {
+	var report jsonReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return err
+	}
+
+	*r = Report{
+		StartedAt: report.StartedAt,
+		Duration:  fromMilliseconds(report.DurationMs),
+		Dialer:    report.Dialer,
+		Results:   report.Results,
+	}
+
+	return nil
+}
+
+// WriteJSON writes the report as indented JSON.
+func WriteJSON(w io.Writer, report *Report) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// ReadJSON reads a report written by WriteJSON.
+func ReadJSON(r io.Reader) (*Report, error) {
+	report := &Report{}
+	if err := json.NewDecoder(r).Decode(report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// csvHeader are the columns written by WriteCSV.
+var csvHeader = []string{"ip", "port", "protocol", "state", "service", "latency_ms", "attempts", "banner", "error"}
+
+// WriteCSV writes a row for each result of the report, with a header row.
+func WriteCSV(w io.Writer, report *Report) error {
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, result := range report.Results {
+		errorMessage := ""
+		if result.Error != nil {
+			errorMessage = result.Error.Error()
+		}
+
+		if err := writer.Write([]string{
+			result.IP,
+			strconv.Itoa(result.Port),
+			"tcp",
+			string(result.State),
+			result.ServiceName(),
+			strconv.FormatFloat(milliseconds(result.Latency), 'f', 3, 64),
+			strconv.Itoa(result.Attempts),
+			result.Banner,
+			errorMessage,
+		}); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
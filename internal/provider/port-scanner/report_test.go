@@ -0,0 +1,59 @@
+package scanner
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testReport() *Report {
+	return &Report{
+		StartedAt: time.Date(2020, 7, 1, 12, 0, 0, 0, time.UTC),
+		Duration:  1500 * time.Millisecond,
+		Dialer:    "direct",
+		Results: []PortScanResult{
+			{IP: "192.168.1.1", Port: 22, Open: true, State: PortStateOpen, Attempts: 1, Latency: time.Millisecond, Banner: "SSH-2.0-OpenSSH_8.2"},
+			{IP: "192.168.1.1", Port: 23, State: PortStateClosed, Attempts: 1, Error: errors.New("connection refused")},
+		},
+	}
+}
+
+func Test_Report_json(t *testing.T) {
+	report := testReport()
+
+	buf := &bytes.Buffer{}
+	if err := WriteJSON(buf, report); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buf.String(), `"duration_ms": 1500`) {
+		t.Errorf("Expected the duration in milliseconds, got:\n%s", buf)
+	}
+
+	decoded, err := ReadJSON(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(report, decoded) {
+		t.Errorf("Expected %+v, got %+v", report, decoded)
+	}
+}
+
+func Test_WriteCSV(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := WriteCSV(buf, testReport()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `ip,port,protocol,state,service,latency_ms,attempts,banner,error
+192.168.1.1,22,tcp,open,ssh,1.000,1,SSH-2.0-OpenSSH_8.2,
+192.168.1.1,23,tcp,closed,telnet,0.000,1,,connection refused
+`
+	if buf.String() != want {
+		t.Errorf("Expected:\n%s\ngot:\n%s", want, buf)
+	}
+}
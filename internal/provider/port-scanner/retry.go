@@ -0,0 +1,113 @@
+package scanner
+
+import "time"
+
+// RetryPolicy controls how ports that appear filtered are retried, since a
+// single dropped SYN would otherwise make an open port look filtered.
+type RetryPolicy struct {
+	// Attempts is the total number of dials for a filtered port, including
+	// the first one. Defaults to 1, which disables retries.
+	Attempts int
+	// Backoff is the delay before the first retry, doubled for every retry after it.
+	Backoff time.Duration
+}
+
+// maxConfirmationRounds bounds how many rounds are spent, as a multiple of
+// Options.Confirmations, trying to get consistent results for a port.
+const maxConfirmationRounds = 3
+
+// probe scans a single port, retrying it and confirming its state according to
+// the scan options. The caller must have already acquired a slot from the
+// concurrency controller.
+//
+// When the state of the port can't be confirmed within the allowed rounds,
+// the most frequently observed state is reported, preferring open, then
+// closed, then filtered when tied. The probers are run once the port is known
+// to be open.
+func (s *scan) probe(ip string, port int) PortScanResult {
+	result := s.confirm(ip, port)
+	s.runProbers(&result)
+	return result
+}
+
+// confirm scans a single port, retrying it and confirming its state according
+// to the scan options.
+func (s *scan) confirm(ip string, port int) PortScanResult {
+	result := s.retry(ip, port)
+	if s.opts.Confirmations <= 1 {
+		return result
+	}
+
+	var (
+		attempts    = result.Attempts
+		consecutive = 1
+		counts      = map[PortState]int{result.State: 1}
+		byState     = map[PortState]PortScanResult{result.State: result}
+	)
+
+	for round := 1; consecutive < s.opts.Confirmations && round < s.opts.Confirmations*maxConfirmationRounds; round++ {
+		s.wait(s.ctx, ip)
+		if controller.Acquire(s.ctx) != nil {
+			break
+		}
+
+		next := s.retry(ip, port)
+		attempts += next.Attempts
+
+		if next.State == result.State {
+			consecutive++
+		} else {
+			consecutive = 1
+		}
+
+		result = next
+		counts[next.State]++
+		byState[next.State] = next
+	}
+
+	if consecutive < s.opts.Confirmations {
+		var best PortState
+		for _, state := range []PortState{PortStateOpen, PortStateClosed, PortStateFiltered} {
+			if counts[state] > counts[best] {
+				best = state
+			}
+		}
+		result = byState[best]
+	}
+
+	result.Attempts = attempts
+	return result
+}
+
+// retry scans a single port, retrying it with an exponential backoff while it
+// appears filtered. The caller must have already acquired a slot from the
+// concurrency controller.
+func (s *scan) retry(ip string, port int) PortScanResult {
+	var (
+		result   PortScanResult
+		attempts int
+		backoff  = s.opts.Retry.Backoff
+	)
+
+	for attempt := 1; ; attempt++ {
+		result = s.dial(ip, port)
+		attempts += result.Attempts
+
+		if result.State != PortStateFiltered || attempt >= s.opts.Retry.Attempts {
+			break
+		}
+
+		if sleep(s.ctx, backoff) != nil {
+			break
+		}
+		backoff *= 2
+
+		s.wait(s.ctx, ip)
+		if controller.Acquire(s.ctx) != nil {
+			break
+		}
+	}
+
+	result.Attempts = attempts
+	return result
+}
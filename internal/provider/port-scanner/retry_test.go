@@ -0,0 +1,139 @@
+package scanner
+
+import (
+	"context"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// scriptedDialer returns the scripted errors in order, a nil error meaning a
+// successful connection, and repeats the last one when the script runs out.
+type scriptedDialer struct {
+	mu     sync.Mutex
+	script []error
+	dials  int
+}
+
+func (d *scriptedDialer) DialTimeout(network, address string, timeout time.Duration) (net.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	err := d.script[len(d.script)-1]
+	if d.dials < len(d.script) {
+		err = d.script[d.dials]
+	}
+	d.dials++
+
+	if err != nil {
+		return nil, err
+	}
+	client, server := net.Pipe()
+	server.Close()
+	return client, nil
+}
+
+func (d *scriptedDialer) Close() error {
+	return nil
+}
+
+var (
+	errScriptedTimeout = context.DeadlineExceeded
+	errScriptedRefused = &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+)
+
+func Test_scan_probe_retry(t *testing.T) {
+	d := &scriptedDialer{script: []error{errScriptedTimeout, errScriptedTimeout, nil}}
+	s := newScan(d, &Options{Retry: RetryPolicy{Attempts: 3, Backoff: time.Millisecond}})
+
+	controller.Acquire(context.Background())
+	result := s.probe("192.0.2.1", 22)
+
+	if result.State != PortStateOpen {
+		t.Fatalf("Expected port to be open after retries, got %q", result.State)
+	}
+	if result.Attempts != 3 {
+		t.Fatalf("Expected 3 attempts, got %d", result.Attempts)
+	}
+}
+
+func Test_scan_probe_retryOnlyFiltered(t *testing.T) {
+	d := &scriptedDialer{script: []error{errScriptedRefused, nil}}
+	s := newScan(d, &Options{Retry: RetryPolicy{Attempts: 3}})
+
+	controller.Acquire(context.Background())
+	result := s.probe("192.0.2.1", 22)
+
+	if result.State != PortStateClosed {
+		t.Fatalf("Expected closed port not to be retried, got %q", result.State)
+	}
+	if result.Attempts != 1 {
+		t.Fatalf("Expected 1 attempt, got %d", result.Attempts)
+	}
+}
+
+func Test_scan_probe_confirmations(t *testing.T) {
+	d := &scriptedDialer{script: []error{nil, errScriptedTimeout, nil, nil}}
+	s := newScan(d, &Options{Confirmations: 2})
+
+	controller.Acquire(context.Background())
+	result := s.probe("192.0.2.1", 22)
+
+	if result.State != PortStateOpen {
+		t.Fatalf("Expected port to be confirmed open, got %q", result.State)
+	}
+	if result.Attempts != 4 {
+		t.Fatalf("Expected 4 attempts to confirm the port, got %d", result.Attempts)
+	}
+}
+
+func Test_scan_probe_confirmationsFlapping(t *testing.T) {
+	d := &scriptedDialer{script: []error{nil, errScriptedTimeout, nil, errScriptedTimeout, nil, errScriptedTimeout}}
+	s := newScan(d, &Options{Confirmations: 2})
+
+	controller.Acquire(context.Background())
+	result := s.probe("192.0.2.1", 22)
+
+	if result.State != PortStateOpen {
+		t.Fatalf("Expected flapping port to be reported open when tied, got %q", result.State)
+	}
+	if result.Attempts != 6 {
+		t.Fatalf("Expected 6 attempts, got %d", result.Attempts)
+	}
+}
+
+func Test_scan_probe_probers(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register(echoProber{"first"}, nil, nil)
+	registry.Register(echoProber{"failing"}, nil, nil)
+
+	d := &scriptedDialer{script: []error{nil, errScriptedTimeout, nil, nil}}
+	s := newScan(d, &Options{
+		Confirmations: 2,
+		Registry:      registry,
+		Probers:       []string{"first", "failing"},
+		RateLimiters:  []*RateLimiter{NewRateLimiter(20, 1)},
+	})
+
+	start := time.Now()
+
+	controller.Acquire(context.Background())
+	result := s.probe("192.0.2.1", 22)
+
+	// the probers are run once, after the 4 dials confirming the port, with a dial each
+	if len(result.Findings) != 2 {
+		t.Fatalf("Expected the probers to run once, got %v", result.Findings)
+	}
+	if result.Attempts != 4 || result.ProberDials != 2 || d.dials != 6 {
+		t.Fatalf("Expected 4 attempts and 2 prober dials, got %d attempts, %d prober dials and %d dials", result.Attempts, result.ProberDials, d.dials)
+	}
+
+	// the 3 confirmation rounds and 2 prober dials wait for the rate limiter,
+	// the first of them using its burst
+	if elapsed := time.Since(start); elapsed < 4*45*time.Millisecond {
+		t.Errorf("Expected the dials of the probers to be rate limited, took %s", elapsed)
+	}
+}
@@ -0,0 +1,101 @@
+package scanner
+
+import "sort"
+
+// Risk is a known-dangerous exposure of a service listening on a TCP port.
+type Risk struct {
+	// ID identifies the risk, such as "redis".
+	ID string
+	// Port is the TCP port the service usually listens on.
+	Port int
+	// Service is the name of the service.
+	Service string
+	// Severity is how severe exposing the service is.
+	Severity Severity
+	// Rationale explains why exposing the service is dangerous.
+	Rationale string
+}
+
+// risks is the catalog of known-dangerous exposures, keyed by port.
+var risks = map[int]Risk{
+	23: {
+		ID:        "telnet",
+		Service:   "telnet",
+		Severity:  SeverityHigh,
+		Rationale: "Telnet sends credentials and sessions in cleartext, and is a common target of credential stuffing botnets. Use SSH instead.",
+	},
+	445: {
+		ID:        "smb",
+		Service:   "SMB",
+		Severity:  SeverityHigh,
+		Rationale: "SMB exposed to untrusted networks is used by wormable exploits such as EternalBlue and by ransomware to spread. Only allow it on internal networks.",
+	},
+	2375: {
+		ID:        "docker",
+		Service:   "unauthenticated Docker API",
+		Severity:  SeverityCritical,
+		Rationale: "The plain HTTP Docker API has no authentication, anyone who can reach it can start privileged containers and take over the host. Use the TLS port 2376 with client certificates, or an SSH connection.",
+	},
+	2379: {
+		ID:        "etcd",
+		Service:   "etcd",
+		Severity:  SeverityCritical,
+		Rationale: "etcd stores the state of Kubernetes clusters, including secrets, and often doesn't require client certificates. Only allow the control plane to reach it.",
+	},
+	3389: {
+		ID:        "rdp",
+		Service:   "RDP",
+		Severity:  SeverityHigh,
+		Rationale: "RDP is continuously brute forced and has had pre-authentication remote code execution vulnerabilities such as BlueKeep. Put it behind a VPN or bastion.",
+	},
+	6379: {
+		ID:        "redis",
+		Service:   "Redis",
+		Severity:  SeverityHigh,
+		Rationale: "Redis doesn't require authentication by default, and its commands can be abused to write files and execute code on the host. Only allow the clients that need it.",
+	},
+	9200: {
+		ID:        "elasticsearch",
+		Service:   "Elasticsearch",
+		Severity:  SeverityHigh,
+		Rationale: "Elasticsearch clusters without security enabled expose, and let anyone delete, all of their data. Exposed clusters are a frequent source of data breaches.",
+	},
+	10250: {
+		ID:        "kubelet",
+		Service:   "kubelet API",
+		Severity:  SeverityCritical,
+		Rationale: "When anonymous authentication is enabled, the kubelet API lets anyone run commands in the pods of the node. Only allow the control plane to reach it.",
+	},
+	11211: {
+		ID:        "memcached",
+		Service:   "Memcached",
+		Severity:  SeverityMedium,
+		Rationale: "Memcached has no authentication, exposing the cached data to anyone, and is abused for amplification attacks when UDP is enabled.",
+	},
+}
+
+func init() {
+	for port, risk := range risks {
+		risk.Port = port
+		risks[port] = risk
+	}
+}
+
+// RiskyService returns the known-dangerous exposure of the service usually
+// listening on the TCP port, if any.
+func RiskyService(port int) (Risk, bool) {
+	risk, ok := risks[port]
+	return risk, ok
+}
+
+// Risks returns the catalog of known-dangerous exposures, sorted by port.
+func Risks() []Risk {
+	catalog := make([]Risk, 0, len(risks))
+	for _, risk := range risks {
+		catalog = append(catalog, risk)
+	}
+	sort.Slice(catalog, func(i, j int) bool {
+		return catalog[i].Port < catalog[j].Port
+	})
+	return catalog
+}
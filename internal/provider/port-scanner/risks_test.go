@@ -0,0 +1,32 @@
+package scanner
+
+import "testing"
+
+func Test_Risks(t *testing.T) {
+	catalog := Risks()
+	if len(catalog) != len(risks) {
+		t.Fatalf("Expected %d risks, got %d", len(risks), len(catalog))
+	}
+
+	ids := map[string]bool{}
+	for i, risk := range catalog {
+		if i > 0 && catalog[i-1].Port >= risk.Port {
+			t.Errorf("Expected the risks to be sorted by port, got %d after %d", risk.Port, catalog[i-1].Port)
+		}
+		if ids[risk.ID] {
+			t.Errorf("Duplicate risk ID %q", risk.ID)
+		}
+		ids[risk.ID] = true
+
+		if risk.Severity.rank() < SeverityMedium.rank() || risk.Rationale == "" {
+			t.Errorf("Expected risk %q to have a severity and rationale, got %+v", risk.ID, risk)
+		}
+	}
+
+	if risk, ok := RiskyService(6379); !ok || risk.ID != "redis" || risk.Port != 6379 {
+		t.Errorf("Expected Redis to be a risky service, got %+v", risk)
+	}
+	if _, ok := RiskyService(443); ok {
+		t.Error("Expected HTTPS not to be a risky service")
+	}
+}
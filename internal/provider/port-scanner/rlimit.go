@@ -0,0 +1,54 @@
+//go:build !windows
+// +build !windows
+
+package scanner
+
+import (
+	"math"
+	"syscall"
+)
+
+// maxOpenFiles is the highest soft RLIMIT_NOFILE value RaiseOpenFileLimit will
+// request, which is plenty for dialing every TCP port at once.
+const maxOpenFiles = 1 << 16
+
+func ulimit() int {
+	var rLimit syscall.Rlimit
+	err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rLimit)
+	if err != nil {
+		// default to 256
+		return 256
+	}
+	if uint64(rLimit.Cur) > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	return int(rLimit.Cur)
+}
+
+// RaiseOpenFileLimit raises the soft RLIMIT_NOFILE limit towards the hard limit,
+// allowing more dials to be in-flight at once. It returns the resulting soft limit.
+func RaiseOpenFileLimit() (int, error) {
+	var rLimit syscall.Rlimit
+	err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rLimit)
+	if err != nil {
+		return 0, err
+	}
+
+	if uint64(rLimit.Cur) >= maxOpenFiles || rLimit.Cur >= rLimit.Max {
+		return ulimit(), nil
+	}
+
+	rLimit.Cur = rLimit.Max
+	if uint64(rLimit.Max) > maxOpenFiles {
+		rLimit.Cur = maxOpenFiles
+	}
+
+	err = syscall.Setrlimit(syscall.RLIMIT_NOFILE, &rLimit)
+	if err != nil {
+		return 0, err
+	}
+
+	limit := ulimit()
+	controller.SetMax(limit)
+	return limit, nil
+}
@@ -0,0 +1,13 @@
+package scanner
+
+import "errors"
+
+func ulimit() int {
+	// windows has no RLIMIT_NOFILE, default to 256
+	return 256
+}
+
+// RaiseOpenFileLimit is not supported on windows.
+func RaiseOpenFileLimit() (int, error) {
+	return ulimit(), errors.New("raising the open file limit is not supported on windows")
+}
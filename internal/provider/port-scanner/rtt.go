@@ -0,0 +1,100 @@
+package scanner
+
+import (
+	"sync"
+	"time"
+)
+
+// TimeoutMode controls how the per-port dial timeout is chosen.
+type TimeoutMode string
+
+const (
+	// TimeoutModeFixed always uses Options.TimeoutPerPort.
+	TimeoutModeFixed TimeoutMode = "fixed"
+	// TimeoutModeAdaptive derives the timeout for each target host from
+	// round trip times measured during the scan, bounded by
+	// Options.MinTimeoutPerPort and Options.TimeoutPerPort.
+	TimeoutModeAdaptive TimeoutMode = "adaptive"
+)
+
+// DefaultMinTimeoutPerPort is the default lower bound for adaptive timeouts.
+var DefaultMinTimeoutPerPort = 250 * time.Millisecond
+
+// minRTTSamples is the number of round trip times measured before the
+// estimate is trusted over the maximum timeout.
+const minRTTSamples = 3
+
+// rttEstimator estimates the round trip time to a single host, using the
+// smoothed RTT (SRTT) and RTT variance (RTTVAR) calculations from RFC 6298.
+type rttEstimator struct {
+	mu      sync.Mutex
+	srtt    time.Duration
+	rttvar  time.Duration
+	samples int
+}
+
+// Observe records a measured round trip time.
+func (e *rttEstimator) Observe(rtt time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.samples == 0 {
+		e.srtt = rtt
+		e.rttvar = rtt / 2
+	} else {
+		delta := e.srtt - rtt
+		if delta < 0 {
+			delta = -delta
+		}
+		// RTTVAR = 3/4 * RTTVAR + 1/4 * |SRTT - R|
+		e.rttvar = (3*e.rttvar + delta) / 4
+		// SRTT = 7/8 * SRTT + 1/8 * R
+		e.srtt = (7*e.srtt + rtt) / 8
+	}
+	e.samples++
+}
+
+// Timeout returns SRTT + 4 * RTTVAR bounded by min and max, or max until
+// enough round trip times have been measured.
+func (e *rttEstimator) Timeout(min, max time.Duration) time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.samples < minRTTSamples {
+		return max
+	}
+
+	timeout := e.srtt + 4*e.rttvar
+	if timeout < min {
+		timeout = min
+	}
+	if timeout > max {
+		timeout = max
+	}
+	return timeout
+}
+
+// rttEstimators keeps a separate rttEstimator for every target host.
+type rttEstimators struct {
+	mu    sync.Mutex
+	hosts map[string]*rttEstimator
+}
+
+func newRTTEstimators() *rttEstimators {
+	return &rttEstimators{
+		hosts: map[string]*rttEstimator{},
+	}
+}
+
+// Get returns the estimator for the given host.
+func (e *rttEstimators) Get(host string) *rttEstimator {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	estimator, ok := e.hosts[host]
+	if !ok {
+		estimator = &rttEstimator{}
+		e.hosts[host] = estimator
+	}
+	return estimator
+}
@@ -0,0 +1,49 @@
+package scanner
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_rttEstimator_Timeout(t *testing.T) {
+	var (
+		e   rttEstimator
+		min = 10 * time.Millisecond
+		max = 5 * time.Second
+	)
+
+	if timeout := e.Timeout(min, max); timeout != max {
+		t.Fatalf("Expected max timeout without samples, got %s", timeout)
+	}
+
+	for i := 0; i < 10; i++ {
+		e.Observe(20 * time.Millisecond)
+	}
+
+	timeout := e.Timeout(min, max)
+	if timeout < 20*time.Millisecond || timeout > 100*time.Millisecond {
+		t.Fatalf("Expected timeout close to the measured RTT, got %s", timeout)
+	}
+
+	if timeout := e.Timeout(time.Second, max); timeout != time.Second {
+		t.Fatalf("Expected timeout to be bounded by the min, got %s", timeout)
+	}
+}
+
+func Test_RunWithOptions_adaptiveTimeout(t *testing.T) {
+	s := newScan(DefaultDialer, &Options{TimeoutMode: TimeoutModeAdaptive})
+
+	if timeout := s.timeout("127.0.0.1"); timeout != DefaultTimeoutPerPort {
+		t.Fatalf("Expected %s timeout before any RTT samples, got %s", DefaultTimeoutPerPort, timeout)
+	}
+
+	for port := 5000; port < 5010; port++ {
+		controller.Acquire(context.Background())
+		s.probe("127.0.0.1", port)
+	}
+
+	if timeout := s.timeout("127.0.0.1"); timeout != DefaultMinTimeoutPerPort {
+		t.Fatalf("Expected localhost timeout to shrink to %s, got %s", DefaultMinTimeoutPerPort, timeout)
+	}
+}
@@ -0,0 +1,332 @@
+package scanner
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+)
+
+// Severity is how severe an exposure is.
+type Severity string
+
+// Severities of exposures, from the most to the least severe.
+const (
+	SeverityCritical Severity = "critical"
+	SeverityHigh     Severity = "high"
+	SeverityMedium   Severity = "medium"
+	SeverityLow      Severity = "low"
+)
+
+// rank orders the severities, from 1 for low to 4 for critical.
+func (s Severity) rank() int {
+	switch s {
+	case SeverityCritical:
+		return 4
+	case SeverityHigh:
+		return 3
+	case SeverityMedium:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// sarifLevel returns the SARIF level of the severity.
+func (s Severity) sarifLevel() string {
+	switch s {
+	case SeverityCritical, SeverityHigh:
+		return "error"
+	case SeverityMedium:
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// securitySeverity returns the CVSS like score code scanning dashboards use
+// to rank the findings.
+func (s Severity) securitySeverity() string {
+	switch s {
+	case SeverityCritical:
+		return "9.5"
+	case SeverityHigh:
+		return "8.0"
+	case SeverityMedium:
+		return "5.5"
+	default:
+		return "2.0"
+	}
+}
+
+// ExposurePolicy decides which open ports are unexpected exposures.
+type ExposurePolicy struct {
+	// AllowedPorts are allowed to be open on every host.
+	AllowedPorts []int
+	// ForbiddenPorts are never allowed to be open, even when listed in AllowedPorts.
+	ForbiddenPorts []int
+	// AllowUnlisted allows the open ports that are neither allowed nor forbidden.
+	AllowUnlisted bool
+	// Suppressions are the accepted exposures of known-dangerous services,
+	// keyed by the ID of their Risk, with the reason they were accepted.
+	Suppressions map[string]string
+}
+
+// Allowed returns true when the port is allowed to be open.
+func (p *ExposurePolicy) Allowed(port int) bool {
+	if p.forbidden(port) {
+		return false
+	}
+	for _, allowed := range p.AllowedPorts {
+		if allowed == port {
+			return true
+		}
+	}
+	return p.AllowUnlisted
+}
+
+func (p *ExposurePolicy) forbidden(port int) bool {
+	for _, forbidden := range p.ForbiddenPorts {
+		if forbidden == port {
+			return true
+		}
+	}
+	return false
+}
+
+// Exposure is an open port that isn't allowed by an ExposurePolicy.
+type Exposure struct {
+	PortScanResult
+	// Forbidden is true when the port is explicitly forbidden, instead of only
+	// not being allowed.
+	Forbidden bool
+	// Severity is high for forbidden ports, and medium otherwise, unless
+	// exposing the service is known to be more severe.
+	Severity Severity
+	// Suppressed is true when the exposure is a known-dangerous service whose
+	// risk is listed in the Suppressions of the policy.
+	Suppressed bool
+	// SuppressionReason is the reason the exposure was accepted.
+	SuppressionReason string
+}
+
+// Exposures returns the open ports of the results that aren't allowed by the
+// policy. Suppressed exposures are kept, flagged with the reason they were
+// suppressed.
+func (p *ExposurePolicy) Exposures(results []PortScanResult) []Exposure {
+	exposures := []Exposure{}
+	for _, result := range results {
+		if !result.Open || p.Allowed(result.Port) {
+			continue
+		}
+
+		exposure := Exposure{PortScanResult: result, Severity: SeverityMedium}
+		if p.forbidden(result.Port) {
+			exposure.Forbidden = true
+			exposure.Severity = SeverityHigh
+		}
+		if risk, ok := RiskyService(result.Port); ok {
+			if risk.Severity.rank() > exposure.Severity.rank() {
+				exposure.Severity = risk.Severity
+			}
+			exposure.SuppressionReason, exposure.Suppressed = p.Suppressions[risk.ID]
+		}
+		exposures = append(exposures, exposure)
+	}
+	return exposures
+}
+
+// sarifSchema is the JSON schema of SARIF 2.1.0 logs.
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	Name                 string             `json:"name"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	FullDescription      sarifMessage       `json:"fullDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+	Properties           sarifProperties    `json:"properties"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifProperties struct {
+	SecuritySeverity string   `json:"security-severity,omitempty"`
+	Tags             []string `json:"tags,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string             `json:"ruleId"`
+	RuleIndex           int                `json:"ruleIndex"`
+	Level               string             `json:"level"`
+	Message             sarifMessage       `json:"message"`
+	Locations           []sarifLocation    `json:"locations"`
+	PartialFingerprints map[string]string  `json:"partialFingerprints"`
+	Suppressions        []sarifSuppression `json:"suppressions,omitempty"`
+	Properties          sarifProperties    `json:"properties"`
+}
+
+type sarifSuppression struct {
+	Kind          string `json:"kind"`
+	Status        string `json:"status"`
+	Justification string `json:"justification,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation  `json:"physicalLocation"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifLogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// sarifRuleID returns the ID of the rule for exposures of the service,
+// which is "exposed-port" for ports without a well-known service.
+func sarifRuleID(service string) string {
+	if service == "" {
+		return "exposed-port"
+	}
+	return "exposed-" + service
+}
+
+// newSARIFRule returns the rule for exposures of the service on the port.
+// The rules of known-dangerous exposures explain why they're dangerous.
+func newSARIFRule(service string, port int) sarifRule {
+	var (
+		name        = "Exposed port"
+		description = "An open TCP port that isn't allowed by the exposure policy."
+		severity    = SeverityMedium
+	)
+
+	if service != "" {
+		name = fmt.Sprintf("Exposed %s service", service)
+		description = fmt.Sprintf("An open TCP port usually serving %s that isn't allowed by the exposure policy.", service)
+	}
+	if risk, ok := RiskyService(port); ok && service == ServiceName(port) {
+		name = fmt.Sprintf("Exposed %s service", risk.Service)
+		description = risk.Rationale
+		severity = risk.Severity
+	}
+
+	return sarifRule{
+		ID:                   sarifRuleID(service),
+		Name:                 name,
+		ShortDescription:     sarifMessage{Text: name},
+		FullDescription:      sarifMessage{Text: description},
+		DefaultConfiguration: sarifConfiguration{Level: severity.sarifLevel()},
+		Properties: sarifProperties{
+			SecuritySeverity: severity.securitySeverity(),
+			Tags:             []string{"security", "network"},
+		},
+	}
+}
+
+// WriteSARIF writes the exposures of the report found with the policy as a
+// SARIF 2.1.0 log, with a rule for each exposed service and a result located
+// at the "host:port" endpoint of each exposure. Suppressed exposures are
+// written as accepted external suppressions, so dashboards can hide them.
+func WriteSARIF(w io.Writer, report *Report, policy *ExposurePolicy) error {
+	var (
+		rules     = []sarifRule{}
+		ruleIndex = map[string]int{}
+		results   = []sarifResult{}
+	)
+
+	for _, exposure := range policy.Exposures(report.Results) {
+		service := exposure.ServiceName()
+
+		id := sarifRuleID(service)
+		index, ok := ruleIndex[id]
+		if !ok {
+			index = len(rules)
+			ruleIndex[id] = index
+			rules = append(rules, newSARIFRule(service, exposure.Port))
+		}
+
+		endpoint := net.JoinHostPort(exposure.IP, strconv.Itoa(exposure.Port))
+
+		port := fmt.Sprintf("%d/tcp", exposure.Port)
+		if service != "" {
+			port = fmt.Sprintf("%d/tcp (%s)", exposure.Port, service)
+		}
+		reason := "isn't allowed"
+		if exposure.Forbidden {
+			reason = "is forbidden"
+		}
+
+		var suppressions []sarifSuppression
+		if exposure.Suppressed {
+			suppressions = append(suppressions, sarifSuppression{Kind: "external", Status: "accepted", Justification: exposure.SuppressionReason})
+		}
+
+		results = append(results, sarifResult{
+			RuleID:    id,
+			RuleIndex: index,
+			Level:     exposure.Severity.sarifLevel(),
+			Message:   sarifMessage{Text: fmt.Sprintf("Port %s is open on %s, which %s by the exposure policy.", port, exposure.IP, reason)},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: "tcp://" + endpoint}},
+				LogicalLocations: []sarifLogicalLocation{{Name: endpoint, FullyQualifiedName: endpoint, Kind: "endpoint"}},
+			}},
+			PartialFingerprints: map[string]string{"endpoint/v1": endpoint + "/tcp"},
+			Suppressions:        suppressions,
+			Properties:          sarifProperties{SecuritySeverity: exposure.Severity.securitySeverity()},
+		})
+	}
+
+	log := sarifLog{
+		Schema:  sarifSchema,
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "port-scan",
+				InformationURI: "https://github.com/picatz/terraform-provider-port-scan",
+				Rules:          rules,
+			}},
+			Results: results,
+		}},
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(log)
+}
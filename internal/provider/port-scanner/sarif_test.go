@@ -0,0 +1,138 @@
+package scanner
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func Test_ExposurePolicy(t *testing.T) {
+	policy := &ExposurePolicy{AllowedPorts: []int{22, 443}, ForbiddenPorts: []int{22, 23}}
+
+	for port, want := range map[int]bool{22: false, 23: false, 443: true, 8080: false} {
+		if allowed := policy.Allowed(port); allowed != want {
+			t.Errorf("Expected port %d to be allowed %v, got %v", port, want, allowed)
+		}
+	}
+
+	policy.AllowUnlisted = true
+	if !policy.Allowed(8080) || policy.Allowed(23) {
+		t.Error("Expected unlisted ports to be allowed, except forbidden ones")
+	}
+}
+
+func Test_WriteSARIF(t *testing.T) {
+	report := &Report{
+		Results: []PortScanResult{
+			{IP: "10.0.0.1", Port: 22, Open: true, State: PortStateOpen},
+			{IP: "10.0.0.1", Port: 23, Open: true, State: PortStateOpen},
+			{IP: "10.0.0.1", Port: 443, Open: true, State: PortStateOpen},
+			{IP: "10.0.0.1", Port: 445, State: PortStateClosed},
+			{IP: "10.0.0.2", Port: 23, Open: true, State: PortStateOpen},
+			{IP: "::1", Port: 31337, Open: true, State: PortStateOpen},
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := WriteSARIF(buf, report, &ExposurePolicy{AllowedPorts: []int{443}, ForbiddenPorts: []int{23}}); err != nil {
+		t.Fatal(err)
+	}
+
+	log := sarifLog{}
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("Expected a single SARIF 2.1.0 run, got %+v", log)
+	}
+
+	run := log.Runs[0]
+
+	rules := []string{}
+	for _, rule := range run.Tool.Driver.Rules {
+		rules = append(rules, rule.ID)
+	}
+	if want := []string{"exposed-ssh", "exposed-telnet", "exposed-port"}; len(rules) != len(want) || rules[0] != want[0] || rules[1] != want[1] || rules[2] != want[2] {
+		t.Errorf("Expected a rule for each exposed service %v, got %v", want, rules)
+	}
+
+	if len(run.Results) != 4 {
+		t.Fatalf("Expected 4 results, got %+v", run.Results)
+	}
+
+	telnet := run.Results[1]
+	if telnet.RuleID != "exposed-telnet" || telnet.RuleIndex != 1 || telnet.Level != "error" {
+		t.Errorf("Expected a forbidden telnet error, got %+v", telnet)
+	}
+	if uri := telnet.Locations[0].PhysicalLocation.ArtifactLocation.URI; uri != "tcp://10.0.0.1:23" {
+		t.Errorf("Expected the location of the endpoint, got %q", uri)
+	}
+	if run.Results[2].RuleIndex != 1 {
+		t.Errorf("Expected telnet exposures to share a rule, got %+v", run.Results[2])
+	}
+	if name := run.Results[3].Locations[0].LogicalLocations[0].Name; name != "[::1]:31337" {
+		t.Errorf("Expected IPv6 endpoints in brackets, got %q", name)
+	}
+	if run.Results[0].Level != "warning" {
+		t.Errorf("Expected ports that aren't allowed to be warnings, got %q", run.Results[0].Level)
+	}
+}
+
+func Test_WriteSARIF_risks(t *testing.T) {
+	report := &Report{
+		Results: []PortScanResult{
+			{IP: "10.0.0.1", Port: 2375, Open: true, State: PortStateOpen},
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := WriteSARIF(buf, report, &ExposurePolicy{}); err != nil {
+		t.Fatal(err)
+	}
+
+	log := sarifLog{}
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+
+	rule := log.Runs[0].Tool.Driver.Rules[0]
+	risk, _ := RiskyService(2375)
+	if rule.FullDescription.Text != risk.Rationale || rule.Properties.SecuritySeverity != "9.5" {
+		t.Errorf("Expected the rule to describe the risk, got %+v", rule)
+	}
+	if result := log.Runs[0].Results[0]; result.Level != "error" || result.Properties.SecuritySeverity != "9.5" {
+		t.Errorf("Expected a critical finding, got %+v", result)
+	}
+}
+
+func Test_WriteSARIF_suppressions(t *testing.T) {
+	report := &Report{
+		Results: []PortScanResult{
+			{IP: "10.0.0.1", Port: 3389, Open: true, State: PortStateOpen},
+			{IP: "10.0.0.1", Port: 6379, Open: true, State: PortStateOpen},
+			{IP: "10.0.0.1", Port: 8080, Open: true, State: PortStateOpen},
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := WriteSARIF(buf, report, &ExposurePolicy{Suppressions: map[string]string{"rdp": "only reachable over the VPN"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	log := sarifLog{}
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+
+	results := log.Runs[0].Results
+	if len(results) != 3 {
+		t.Fatalf("Expected every open port to be a finding without allowed ports, got %+v", results)
+	}
+	if suppressions := results[0].Suppressions; len(suppressions) != 1 || suppressions[0].Status != "accepted" || suppressions[0].Justification != "only reachable over the VPN" {
+		t.Errorf("Expected the RDP finding to be suppressed, got %+v", results[0])
+	}
+	if len(results[1].Suppressions) != 0 || len(results[2].Suppressions) != 0 {
+		t.Errorf("Expected only the RDP finding to be suppressed, got %+v", results[1:])
+	}
+}
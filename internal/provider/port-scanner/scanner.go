@@ -2,15 +2,13 @@ package scanner
 
 import (
 	"context"
-	"fmt"
+	"math/rand"
 	"net"
-	"strings"
+	"strconv"
 	"sync"
-	"syscall"
 	"time"
 
 	"golang.org/x/crypto/ssh"
-	"golang.org/x/sync/semaphore"
 )
 
 // Dialer implents an interface to allow for multiple network connection types
@@ -19,12 +17,58 @@ type Dialer interface {
 	Close() error
 }
 
+// PortState is the state of a scanned port.
+type PortState string
+
+const (
+	// PortStateOpen is a port that accepted the connection.
+	PortStateOpen PortState = "open"
+	// PortStateClosed is a port that actively refused the connection.
+	PortStateClosed PortState = "closed"
+	// PortStateFiltered is a port that didn't answer, or couldn't be reached.
+	PortStateFiltered PortState = "filtered"
+)
+
+// portState returns the state of a port given the error from dialing it.
+func portState(err error) PortState {
+	switch {
+	case err == nil:
+		return PortStateOpen
+	case classifyError(err) == outcomeOK:
+		return PortStateClosed
+	default:
+		return PortStateFiltered
+	}
+}
+
 // PortScanResult is the type returned by the Run func result chan
 type PortScanResult struct {
-	IP    string
-	Port  int
-	Open  bool
-	Error error
+	IP       string
+	Port     int
+	Open     bool
+	State    PortState
+	Attempts int
+	// Latency is the time it took to connect, or be refused
+	Latency time.Duration
+	// TCPInfo is only available for open ports dialed directly on linux
+	TCPInfo *TCPInfo
+	// Banner is the first line sent by the service on an open port, only
+	// grabbed when enabled in the scan options
+	Banner string
+	// Service is the name of the service detected on the port, such as by
+	// nmap, empty when only the port number is known
+	Service string
+	// Findings are reported by the probers run on an open port
+	Findings []Finding
+	// ProberDials is the number of connections opened by the probers, which
+	// aren't counted in Attempts
+	ProberDials int
+	Error       error
+}
+
+// TimedOut reports whether the port didn't answer before the dial timeout.
+func (r PortScanResult) TimedOut() bool {
+	return r.Error != nil && classifyError(r.Error) == outcomeTimeout
 }
 
 // DefaultTimeoutPerPort is the default timeout per-port for Run
@@ -43,6 +87,13 @@ func (d *defaultDialer) DialTimeout(network, address string, timeout time.Durati
 	return d.Dialer.DialContext(ctx, network, address)
 }
 
+// DialContext implements the contextDialer interface
+func (d *defaultDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	ctx, cancel := withCancelFrom(ctx, d.ctx)
+	defer cancel()
+	return d.Dialer.DialContext(ctx, network, address)
+}
+
 func (d *defaultDialer) Close() error {
 	d.cancel()
 	return nil
@@ -52,72 +103,320 @@ func (d *defaultDialer) Close() error {
 var DefaultDialer *defaultDialer
 
 func init() {
+	DefaultDialer = newDefaultDialer()
+}
+
+func newDefaultDialer() *defaultDialer {
 	ctx, cancel := context.WithCancel(context.Background())
-	DefaultDialer = &defaultDialer{
+	return &defaultDialer{
 		ctx:            ctx,
 		cancel:         cancel,
 		timeOutPerPort: DefaultTimeoutPerPort,
 	}
 }
 
-var lock *semaphore.Weighted = semaphore.NewWeighted(ulimit())
+// contextDialer is implemented by dialers which can cancel dials in progress
+// when the context of a scan is done.
+type contextDialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
 
-func ulimit() int64 {
-	var rLimit syscall.Rlimit
-	err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rLimit)
-	if err != nil {
-		// default to 256
-		return int64(256)
+// withCancelFrom returns a copy of ctx which is also canceled once other is done.
+func withCancelFrom(ctx, other context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-other.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// NewDialer creates a Dialer that connects directly to the target. Unlike the
+// shared DefaultDialer, closing it only cancels its own outstanding dials.
+func NewDialer() Dialer {
+	return newDefaultDialer()
+}
+
+func scanPort(d Dialer, ip string, port int, timeout time.Duration) PortScanResult {
+	conn, result := dialPort(context.Background(), d, ip, port, timeout)
+	if conn != nil {
+		conn.Close()
 	}
-	return int64(rLimit.Cur)
+	return result
 }
 
-func scanPort(d Dialer, ip string, port int, timeout time.Duration) (result PortScanResult) {
+// dialPort scans a single port like scanPort, but leaves the connection to
+// an open port for the caller to use and close. Dialers implementing
+// contextDialer stop dialing once the context is done.
+func dialPort(ctx context.Context, d Dialer, ip string, port int, timeout time.Duration) (conn net.Conn, result PortScanResult) {
 	result.IP = ip
 	result.Port = port
 
-	target := fmt.Sprintf("%s:%d", ip, port)
+	target := net.JoinHostPort(ip, strconv.Itoa(port))
+
+	var err error
 
-	conn, err := d.DialTimeout("tcp", target, timeout)
+	start := time.Now()
+	if cd, ok := d.(contextDialer); ok {
+		dialCtx, cancel := context.WithTimeout(ctx, timeout)
+		conn, err = cd.DialContext(dialCtx, "tcp", target)
+		cancel()
+	} else {
+		conn, err = d.DialTimeout("tcp", target, timeout)
+	}
+	result.Latency = time.Since(start)
+	result.State = portState(err)
 	if err != nil {
-		if strings.Contains(err.Error(), "too many open files") {
-			for {
-				time.Sleep(timeout)
-				conn, err = d.DialTimeout("tcp", target, timeout)
-				if strings.Contains(err.Error(), "too many open files") {
-					continue
-				} else {
-					result.Error = err
-					return
-				}
-			}
-		} else {
-			result.Error = err
+		result.Error = err
+		return nil, result
+	}
+	result.TCPInfo = tcpInfo(conn)
+	result.Open = true
+	return conn, result
+}
+
+// maxExhaustedAttempts bounds how many times a single port is re-dialed when
+// the dial failed because the local host ran out of sockets or file descriptors.
+const maxExhaustedAttempts = 10
+
+// Options configures a port scan performed by RunWithOptions.
+type Options struct {
+	// TimeoutPerPort is the dial timeout for each port, defaults to DefaultTimeoutPerPort.
+	// When using TimeoutModeAdaptive, this is the upper bound for the timeout.
+	TimeoutPerPort time.Duration
+	// TimeoutMode controls how the dial timeout is chosen, defaults to TimeoutModeFixed.
+	TimeoutMode TimeoutMode
+	// MinTimeoutPerPort is the lower bound for TimeoutModeAdaptive, defaults
+	// to DefaultMinTimeoutPerPort.
+	MinTimeoutPerPort time.Duration
+	// RateLimiters limit the connections per second across all target hosts.
+	// Every limiter must allow a connection before it is made.
+	RateLimiters []*RateLimiter
+	// HostRateLimiters limit the connections per second to each target host.
+	// Every limiter must allow a connection before it is made.
+	HostRateLimiters []*HostRateLimiter
+	// Jitter is the upper bound of a random delay added before each probe.
+	Jitter time.Duration
+	// RandomizePorts scans the ports in a random order.
+	RandomizePorts bool
+	// Retry controls how ports that appear filtered are retried.
+	Retry RetryPolicy
+	// Confirmations is the number of consecutive consistent results required
+	// before the state of a port is reported, defaults to 1.
+	Confirmations int
+	// GrabBanners reads the banner sent by services on open ports, like
+	// adding "banner" to Probers.
+	GrabBanners bool
+	// BannerTimeout is how long to wait for a banner, defaults to DefaultBannerTimeout.
+	BannerTimeout time.Duration
+	// Probers are the names of the probers of the Registry run on open ports.
+	Probers []string
+	// Registry holds the probers, defaults to DefaultRegistry.
+	Registry *Registry
+	// ProbeTimeout bounds each prober, defaults to BannerTimeout.
+	ProbeTimeout time.Duration
+}
+
+// scan holds the state shared by all probes of a single RunWithOptions call.
+type scan struct {
+	ctx    context.Context
+	dialer Dialer
+	opts   Options
+	rtts   *rttEstimators
+
+	rngMu sync.Mutex
+	rng   *rand.Rand
+}
+
+func newScan(d Dialer, opts *Options) *scan {
+	s := &scan{
+		ctx:    context.Background(),
+		dialer: d,
+		rtts:   newRTTEstimators(),
+		rng:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	if opts != nil {
+		s.opts = *opts
+	}
+	if s.opts.TimeoutPerPort <= 0 {
+		s.opts.TimeoutPerPort = DefaultTimeoutPerPort
+	}
+	if s.opts.TimeoutMode == "" {
+		s.opts.TimeoutMode = TimeoutModeFixed
+	}
+	if s.opts.MinTimeoutPerPort <= 0 {
+		s.opts.MinTimeoutPerPort = DefaultMinTimeoutPerPort
+	}
+	if s.opts.MinTimeoutPerPort > s.opts.TimeoutPerPort {
+		s.opts.MinTimeoutPerPort = s.opts.TimeoutPerPort
+	}
+	if s.opts.Retry.Attempts < 1 {
+		s.opts.Retry.Attempts = 1
+	}
+	if s.opts.Confirmations < 1 {
+		s.opts.Confirmations = 1
+	}
+	if s.opts.BannerTimeout <= 0 {
+		s.opts.BannerTimeout = DefaultBannerTimeout
+	}
+	if s.opts.ProbeTimeout <= 0 {
+		s.opts.ProbeTimeout = s.opts.BannerTimeout
+	}
+	if s.opts.Registry == nil {
+		s.opts.Registry = DefaultRegistry
+	}
+	if s.opts.GrabBanners {
+		s.opts.Probers = append([]string{"banner"}, s.opts.Probers...)
+	}
+
+	return s
+}
+
+// timeout returns the dial timeout to use for the given host.
+func (s *scan) timeout(ip string) time.Duration {
+	if s.opts.TimeoutMode == TimeoutModeAdaptive {
+		return s.rtts.Get(ip).Timeout(s.opts.MinTimeoutPerPort, s.opts.TimeoutPerPort)
+	}
+	return s.opts.TimeoutPerPort
+}
+
+// wait blocks until the rate limiters allow another connection to the given
+// host, plus a random jitter.
+func (s *scan) wait(ctx context.Context, ip string) {
+	for _, limiter := range s.opts.RateLimiters {
+		limiter.Wait(ctx)
+	}
+	for _, limiter := range s.opts.HostRateLimiters {
+		limiter.Wait(ctx, ip)
+	}
+	if s.opts.Jitter > 0 {
+		s.rngMu.Lock()
+		jitter := time.Duration(s.rng.Int63n(int64(s.opts.Jitter)))
+		s.rngMu.Unlock()
+		sleep(ctx, jitter)
+	}
+}
+
+// dial scans a single port while holding a slot from the concurrency
+// controller, which must already be acquired by the caller. Dials that fail
+// due to local resource exhaustion are retried once the controller has shrunk
+// the number of in-flight dials.
+func (s *scan) dial(ip string, port int) (result PortScanResult) {
+	attempts := 0
+	for exhausted := 1; ; exhausted++ {
+		var conn net.Conn
+		conn, result = dialPort(s.ctx, s.dialer, ip, port, s.timeout(ip))
+		if conn != nil {
+			conn.Close()
+		}
+
+		outcome := classifyError(result.Error)
+		controller.Release(outcome)
+
+		// both connected and refused dials measure a full round trip
+		if outcome == outcomeOK {
+			s.rtts.Get(ip).Observe(result.Latency)
+		}
+
+		// exhausted dials never made it out of the local host
+		if outcome != outcomeExhausted {
+			attempts++
+		}
+		result.Attempts = attempts
+
+		if outcome != outcomeExhausted || exhausted >= maxExhaustedAttempts {
+			return
+		}
+		if sleep(s.ctx, time.Duration(exhausted)*exhaustedBackoff) != nil || controller.Acquire(s.ctx) != nil {
 			return
 		}
 	}
-	conn.Close()
-	result.Open = true
-	return
+}
+
+// PortRange returns all of the ports from firstPort to lastPort, inclusive.
+func PortRange(firstPort, lastPort int) []int {
+	ports := []int{}
+	for port := firstPort; port <= lastPort; port++ {
+		ports = append(ports, port)
+	}
+	return ports
 }
 
 // Run will perform a port scan for the given IP, starting at the firstPort to the lastPort
 func Run(d Dialer, ip string, firstPort, lastPort int, timeoutPerPort time.Duration) <-chan PortScanResult {
+	return RunWithOptions(d, ip, PortRange(firstPort, lastPort), &Options{TimeoutPerPort: timeoutPerPort})
+}
+
+// RunWithOptions will perform a port scan for the given IP and ports, using the given options
+func RunWithOptions(d Dialer, ip string, ports []int, opts *Options) <-chan PortScanResult {
+	return RunHosts(d, []string{ip}, ports, opts)
+}
+
+// target is a single host and port to probe.
+type target struct {
+	ip   string
+	port int
+}
+
+// RunWithOptionsContext is like RunWithOptions, but stops scanning once the context is done.
+func RunWithOptionsContext(ctx context.Context, d Dialer, ip string, ports []int, opts *Options) <-chan PortScanResult {
+	return RunHostsContext(ctx, d, []string{ip}, ports, opts)
+}
+
+// RunHosts will perform a port scan for the given ports on all of the given
+// IPs in a single pipeline, using the given options. Ports are probed one at
+// a time across all of the hosts, which spreads the connections out instead
+// of hitting one host with all of them at once.
+func RunHosts(d Dialer, ips []string, ports []int, opts *Options) <-chan PortScanResult {
+	return RunHostsContext(context.Background(), d, ips, ports, opts)
+}
+
+// RunHostsContext is like RunHosts, but stops scanning once the context is
+// done. Dials in progress are canceled, and ports that weren't probed, or
+// whose probe was cut short, aren't sent to the results channel, so the
+// results only hold ports with a known state.
+func RunHostsContext(ctx context.Context, d Dialer, ips []string, ports []int, opts *Options) <-chan PortScanResult {
 	results := make(chan PortScanResult)
 
+	s := newScan(d, opts)
+	s.ctx = ctx
+
+	targets := make([]target, 0, len(ips)*len(ports))
+	for _, port := range ports {
+		for _, ip := range ips {
+			targets = append(targets, target{ip: ip, port: port})
+		}
+	}
+
+	if s.opts.RandomizePorts {
+		s.rng.Shuffle(len(targets), func(i, j int) {
+			targets[i], targets[j] = targets[j], targets[i]
+		})
+	}
+
 	go func() {
 		defer close(results)
 
 		wg := sync.WaitGroup{}
 
-		for port := firstPort; port <= lastPort; port++ {
-			lock.Acquire(context.Background(), 1)
+		for _, t := range targets {
+			s.wait(ctx, t.ip)
+			if ctx.Err() != nil || controller.Acquire(ctx) != nil {
+				break
+			}
 			wg.Add(1)
-			go func(port int) {
-				defer lock.Release(1)
+			go func(t target) {
 				defer wg.Done()
-				results <- scanPort(d, ip, port, timeoutPerPort)
-			}(port)
+				result := s.probe(t.ip, t.port)
+				if ctx.Err() != nil && result.State == PortStateFiltered {
+					return
+				}
+				results <- result
+			}(t)
 		}
 
 		wg.Wait()
@@ -137,40 +436,41 @@ type SSHBastionScanner struct {
 
 // DialTimeout implements the Dialer interface
 func (b *SSHBastionScanner) DialTimeout(network, address string, timeout time.Duration) (net.Conn, error) {
-	// ctx, cancel := context.WithTimeout(b.ctx, timeout)
-	// defer cancel()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return b.DialContext(ctx, network, address)
+}
+
+// DialContext implements the contextDialer interface
+func (b *SSHBastionScanner) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	ctx, cancel := withCancelFrom(ctx, b.ctx)
+	defer cancel()
 
-	connChan := make(chan net.Conn, 1)
+	// connChan is unbuffered, so a connection established after the timeout
+	// is closed instead of being leaked
+	connChan := make(chan net.Conn)
 	errChan := make(chan error, 1)
 
 	go func() {
-		// log.Printf("[DEBUG] dialing %s", address)
-		// defer log.Printf("[DEBUG] done dialing %s", address)
 		conn, err := b.Client.Dial(network, address)
 		if err != nil {
-			select {
-			// case <-ctx.Done():
-			case errChan <- err:
-			}
+			errChan <- err
 			return
 		}
 
 		select {
-		//case <-ctx.Done():
-		//	conn.Close()
+		case <-ctx.Done():
+			conn.Close()
 		case connChan <- conn:
 		}
 	}()
 
 	select {
-	// case <-ctx.Done():
-	// 	log.Printf("[DEBUG] ctx done %s", address)
-	// 	return nil, ctx.Err()
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	case conn := <-connChan:
-		// log.Printf("[DEBUG] got conn %s", address)
 		return conn, nil
 	case err := <-errChan:
-		// log.Printf("[DEBUG] got err %s %q", address, err.Error())
 		return nil, err
 	}
 }
@@ -178,7 +478,7 @@ func (b *SSHBastionScanner) DialTimeout(network, address string, timeout time.Du
 // Close implements the Dialer interface
 func (b *SSHBastionScanner) Close() error {
 	b.cancel()
-	return nil
+	return b.Client.Close()
 }
 
 // NewSSHBastionScanner creates a new SSHBastionScanner Dialer type
@@ -2,6 +2,7 @@ package scanner
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/subtle"
@@ -60,6 +61,9 @@ func Test_scanPort_closed(t *testing.T) {
 	if result.Open {
 		t.Fatalf("Expected port %d to be closed", port)
 	}
+	if result.State != PortStateClosed {
+		t.Fatalf("Expected port %d state to be %q, got %q", port, PortStateClosed, result.State)
+	}
 }
 
 func Test_scanPort_Run(t *testing.T) {
@@ -335,3 +339,86 @@ func hostKey(hostKeyBase64 string) ssh.HostKeyCallback {
 		return nil
 	}
 }
+
+func Test_RunWithOptions_randomizePorts(t *testing.T) {
+	ports := PortRange(5000, 5100)
+
+	seen := map[int]bool{}
+	for result := range RunWithOptions(DefaultDialer, "127.0.0.1", ports, &Options{RandomizePorts: true}) {
+		seen[result.Port] = true
+	}
+
+	if len(seen) != len(ports) {
+		t.Errorf("Expected %d results, got %d", len(ports), len(seen))
+	}
+
+	if ports[0] != 5000 || ports[len(ports)-1] != 5100 {
+		t.Errorf("Expected the given ports not to be shuffled in place")
+	}
+}
+
+func Test_RunHosts(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.2:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer listener.Close()
+
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	open := map[string]bool{}
+	results := 0
+	for result := range RunHosts(DefaultDialer, []string{"127.0.0.1", "127.0.0.2"}, []int{port}, nil) {
+		results++
+		open[result.IP] = result.Open
+	}
+
+	if results != 2 {
+		t.Fatalf("Expected 2 results, got %d", results)
+	}
+	if open["127.0.0.1"] || !open["127.0.0.2"] {
+		t.Fatalf("Expected port %d to only be open on 127.0.0.2, got %v", port, open)
+	}
+}
+
+// blackholeDialer connects to port 1, and never answers on any other port.
+type blackholeDialer struct{}
+
+func (blackholeDialer) DialTimeout(network, address string, timeout time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return blackholeDialer{}.DialContext(ctx, network, address)
+}
+
+func (blackholeDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if strings.HasSuffix(address, ":1") {
+		client, server := net.Pipe()
+		server.Close()
+		return client, nil
+	}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (blackholeDialer) Close() error {
+	return nil
+}
+
+func Test_RunHostsContext_canceled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+
+	results := []PortScanResult{}
+	for result := range RunHostsContext(ctx, blackholeDialer{}, []string{"192.0.2.1"}, PortRange(1, 100), &Options{TimeoutPerPort: time.Minute}) {
+		results = append(results, result)
+	}
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("Expected outstanding dials to be canceled, took %s", elapsed)
+	}
+	if len(results) != 1 || results[0].Port != 1 || !results[0].Open {
+		t.Fatalf("Expected only the open port to be reported, got %+v", results)
+	}
+}
@@ -0,0 +1,98 @@
+package scanner
+
+import "sort"
+
+// services maps well-known TCP ports to the name of the service usually
+// listening on them. IANA service names are used for the registered ports,
+// and application names for the ports that are only used by convention.
+var services = map[int]string{
+	20:    "ftp-data",
+	21:    "ftp",
+	22:    "ssh",
+	23:    "telnet",
+	25:    "smtp",
+	53:    "domain",
+	67:    "bootps",
+	69:    "tftp",
+	80:    "http",
+	88:    "kerberos",
+	110:   "pop3",
+	111:   "sunrpc",
+	119:   "nntp",
+	123:   "ntp",
+	135:   "msrpc",
+	137:   "netbios-ns",
+	139:   "netbios-ssn",
+	143:   "imap",
+	161:   "snmp",
+	179:   "bgp",
+	389:   "ldap",
+	443:   "https",
+	445:   "microsoft-ds",
+	465:   "smtps",
+	500:   "isakmp",
+	514:   "shell",
+	515:   "printer",
+	587:   "submission",
+	631:   "ipp",
+	636:   "ldaps",
+	873:   "rsync",
+	993:   "imaps",
+	995:   "pop3s",
+	1080:  "socks",
+	1433:  "ms-sql-s",
+	1521:  "oracle",
+	1723:  "pptp",
+	1883:  "mqtt",
+	2049:  "nfs",
+	2375:  "docker",
+	2376:  "docker-s",
+	2379:  "etcd-client",
+	2380:  "etcd-server",
+	3306:  "mysql",
+	3389:  "ms-wbt-server",
+	4369:  "epmd",
+	5000:  "upnp",
+	5432:  "postgresql",
+	5601:  "kibana",
+	5672:  "amqp",
+	5900:  "vnc",
+	5984:  "couchdb",
+	6379:  "redis",
+	6443:  "kubernetes-api",
+	8080:  "http-alt",
+	8443:  "https-alt",
+	8500:  "consul",
+	9042:  "cassandra",
+	9092:  "kafka",
+	9200:  "elasticsearch",
+	10250: "kubelet",
+	11211: "memcache",
+	15672: "rabbitmq-mgmt",
+	27017: "mongodb",
+}
+
+// ServiceName returns the name of the service usually listening on the TCP
+// port, or an empty string if it isn't a well-known port.
+func ServiceName(port int) string {
+	return services[port]
+}
+
+// ServiceName returns the name of the service detected on the port, or the
+// name of the service usually listening on it.
+func (r PortScanResult) ServiceName() string {
+	if r.Service != "" {
+		return r.Service
+	}
+	return ServiceName(r.Port)
+}
+
+// WellKnownPorts returns the well-known TCP ports, sorted.
+func WellKnownPorts() []int {
+	ports := make([]int, 0, len(services))
+	for port := range services {
+		ports = append(ports, port)
+	}
+	sort.Ints(ports)
+	return ports
+}
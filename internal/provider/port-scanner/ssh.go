@@ -0,0 +1,48 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// SSHPrivateKeyAuth returns an SSH auth method for the PEM encoded private
+// key, ignoring blank lines and indentation around it.
+func SSHPrivateKeyAuth(key string) (ssh.AuthMethod, error) {
+	var trimmedKey string
+
+	bufioScanner := bufio.NewScanner(bytes.NewReader([]byte(key)))
+	for bufioScanner.Scan() {
+		if len(bufioScanner.Bytes()) > 0 {
+			trimmedKey += strings.TrimSpace(bufioScanner.Text()) + "\n"
+		}
+	}
+
+	signer, err := ssh.ParsePrivateKey([]byte(trimmedKey))
+	if err != nil {
+		return nil, err
+	}
+	return ssh.PublicKeys(signer), nil
+}
+
+// SSHHostKeyCallback returns a host key callback only accepting the base64
+// encoded host key.
+func SSHHostKeyCallback(hostKeyBase64 string) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		// TODO(kent): add check hostname/remote
+		hostKeyBytes, err := base64.StdEncoding.DecodeString(hostKeyBase64)
+		if err != nil {
+			return err
+		}
+		if subtle.ConstantTimeCompare(key.Marshal(), hostKeyBytes) != 1 {
+			return fmt.Errorf("ssh: server host key failed to match")
+		}
+		return nil
+	}
+}
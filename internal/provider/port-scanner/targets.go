@@ -0,0 +1,105 @@
+package scanner
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// MaxTargets is the maximum number of addresses ExpandTargets will expand to.
+var MaxTargets = 65536
+
+// ExpandTargets expands a list of IP addresses, hostnames and CIDR blocks into
+// a list of unique addresses to scan, keeping the order they were given in.
+// The network and broadcast addresses of IPv4 CIDR blocks larger than a /31
+// are skipped.
+func ExpandTargets(targets []string) ([]string, error) {
+	var (
+		seen      = map[string]bool{}
+		addresses = []string{}
+	)
+
+	add := func(address string) error {
+		if seen[address] {
+			return nil
+		}
+		if len(addresses) >= MaxTargets {
+			return fmt.Errorf("too many targets, expanded to more than %d addresses", MaxTargets)
+		}
+		seen[address] = true
+		addresses = append(addresses, address)
+		return nil
+	}
+
+	for _, t := range targets {
+		t = strings.TrimSpace(t)
+
+		if !strings.Contains(t, "/") {
+			if t == "" {
+				return nil, fmt.Errorf("empty target")
+			}
+			if err := add(t); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		ip, network, err := net.ParseCIDR(t)
+		if err != nil {
+			return nil, err
+		}
+
+		ones, bits := network.Mask.Size()
+		if bits-ones > 31 || 1<<uint(bits-ones) > MaxTargets {
+			return nil, fmt.Errorf("CIDR block %q is too large, expands to more than %d addresses", t, MaxTargets)
+		}
+
+		skipEdges := ip.To4() != nil && bits-ones > 1
+
+		first := network.IP.Mask(network.Mask)
+		for current := copyIP(first); network.Contains(current); incrementIP(current) {
+			if skipEdges && (current.Equal(first) || isBroadcast(current, network)) {
+				continue
+			}
+			if err := add(current.String()); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return addresses, nil
+}
+
+func copyIP(ip net.IP) net.IP {
+	c := make(net.IP, len(ip))
+	copy(c, ip)
+	return c
+}
+
+// incrementIP increments the IP address in place.
+func incrementIP(ip net.IP) {
+	for i := len(ip) - 1; i >= 0; i-- {
+		ip[i]++
+		if ip[i] != 0 {
+			return
+		}
+	}
+}
+
+// isBroadcast checks if the IP is the last address of the network.
+func isBroadcast(ip net.IP, network *net.IPNet) bool {
+	ip = ip.To4()
+	if ip == nil {
+		return false
+	}
+	mask := network.Mask
+	if len(mask) == net.IPv6len {
+		mask = mask[12:]
+	}
+	for i := range ip {
+		if ip[i]|mask[i] != 0xff {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,40 @@
+package scanner
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_ExpandTargets(t *testing.T) {
+	tests := []struct {
+		targets []string
+		want    []string
+	}{
+		{[]string{"192.0.2.1"}, []string{"192.0.2.1"}},
+		{[]string{"192.0.2.0/30"}, []string{"192.0.2.1", "192.0.2.2"}},
+		{[]string{"192.0.2.0/31"}, []string{"192.0.2.0", "192.0.2.1"}},
+		{[]string{"192.0.2.7/32", "192.0.2.7"}, []string{"192.0.2.7"}},
+		{[]string{"2001:db8::/127"}, []string{"2001:db8::", "2001:db8::1"}},
+		{[]string{"example.com", "192.0.2.1"}, []string{"example.com", "192.0.2.1"}},
+	}
+
+	for _, test := range tests {
+		got, err := ExpandTargets(test.targets)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ExpandTargets(%v) = %v, want %v", test.targets, got, test.want)
+		}
+	}
+
+	if addresses, err := ExpandTargets([]string{"10.0.0.0/24"}); err != nil || len(addresses) != 254 {
+		t.Errorf("Expected 254 addresses for a /24, got %d (%v)", len(addresses), err)
+	}
+
+	for _, targets := range [][]string{{"10.0.0.0/8"}, {"2001:db8::/64"}, {"not/a/cidr"}, {""}} {
+		if _, err := ExpandTargets(targets); err == nil {
+			t.Errorf("Expected an error expanding %v", targets)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package scanner
+
+import "time"
+
+// TCPInfo holds metrics reported by the kernel for a connection.
+type TCPInfo struct {
+	// RTT is the smoothed round trip time.
+	RTT time.Duration
+	// RTTVar is the round trip time variance.
+	RTTVar time.Duration
+	// Retransmits is the total number of retransmitted segments.
+	Retransmits uint32
+}
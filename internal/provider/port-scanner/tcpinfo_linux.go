@@ -0,0 +1,41 @@
+package scanner
+
+import (
+	"net"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// tcpInfo returns the kernel TCP_INFO metrics for a direct connection, or nil
+// if the connection isn't backed by a TCP socket, like those from an SSH bastion.
+func tcpInfo(conn net.Conn) *TCPInfo {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return nil
+	}
+
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return nil
+	}
+
+	var (
+		info    *unix.TCPInfo
+		infoErr error
+	)
+
+	err = raw.Control(func(fd uintptr) {
+		info, infoErr = unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO)
+	})
+	if err != nil || infoErr != nil {
+		return nil
+	}
+
+	return &TCPInfo{
+		RTT:         time.Duration(info.Rtt) * time.Microsecond,
+		RTTVar:      time.Duration(info.Rttvar) * time.Microsecond,
+		Retransmits: info.Total_retrans,
+	}
+}
@@ -0,0 +1,28 @@
+package scanner
+
+import (
+	"net"
+	"testing"
+)
+
+func Test_scanPort_tcpInfo(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	result := scanPort(DefaultDialer, "127.0.0.1", port, DefaultTimeoutPerPort)
+	if !result.Open {
+		t.Fatalf("Expected port %d to be open", port)
+	}
+	if result.Latency <= 0 {
+		t.Errorf("Expected a connect latency to be measured")
+	}
+	if result.TCPInfo == nil {
+		t.Fatalf("Expected TCP_INFO for a direct connection")
+	}
+	t.Logf("TCP_INFO: %+v", result.TCPInfo)
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package scanner
+
+import "net"
+
+// tcpInfo is only supported on linux.
+func tcpInfo(conn net.Conn) *TCPInfo {
+	return nil
+}
@@ -0,0 +1,69 @@
+package scanner
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// tlsVersions maps TLS versions to their names.
+var tlsVersions = map[uint16]string{
+	tls.VersionTLS10: "TLS 1.0",
+	tls.VersionTLS11: "TLS 1.1",
+	tls.VersionTLS12: "TLS 1.2",
+	tls.VersionTLS13: "TLS 1.3",
+}
+
+// tlsVersionName returns the name of the TLS version.
+func tlsVersionName(version uint16) string {
+	if name, ok := tlsVersions[version]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%04x", version)
+}
+
+// TLSProber performs a TLS handshake and reports the negotiated "version" and
+// "cipher_suite", and the "subject", "issuer", "dns_names" and "not_after" of
+// the certificate presented by the service. The certificate isn't verified,
+// since scanned services are usually addressed by IP.
+type TLSProber struct{}
+
+// Name returns "tls".
+func (TLSProber) Name() string {
+	return "tls"
+}
+
+// Probe performs the TLS handshake.
+func (TLSProber) Probe(ctx context.Context, conn net.Conn, target Target) ([]Finding, error) {
+	client := tls.Client(conn, &tls.Config{
+		InsecureSkipVerify: true,
+		MinVersion:         tls.VersionTLS10,
+	})
+	if err := client.Handshake(); err != nil {
+		return nil, fmt.Errorf("handshake: %s", err)
+	}
+
+	state := client.ConnectionState()
+
+	findings := []Finding{
+		{Prober: "tls", Name: "version", Value: tlsVersionName(state.Version)},
+		{Prober: "tls", Name: "cipher_suite", Value: tls.CipherSuiteName(state.CipherSuite)},
+	}
+
+	if len(state.PeerCertificates) > 0 {
+		cert := state.PeerCertificates[0]
+		findings = append(findings,
+			Finding{Prober: "tls", Name: "subject", Value: cert.Subject.String()},
+			Finding{Prober: "tls", Name: "issuer", Value: cert.Issuer.String()},
+			Finding{Prober: "tls", Name: "not_after", Value: cert.NotAfter.UTC().Format(time.RFC3339)},
+		)
+		if len(cert.DNSNames) > 0 {
+			findings = append(findings, Finding{Prober: "tls", Name: "dns_names", Value: strings.Join(cert.DNSNames, ",")})
+		}
+	}
+
+	return findings, nil
+}
@@ -0,0 +1,184 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WaitMode controls how many of the ports Wait requires to be open.
+type WaitMode string
+
+const (
+	// WaitModeAll waits until all of the ports are open.
+	WaitModeAll WaitMode = "all"
+	// WaitModeAny waits until any of the ports is open.
+	WaitModeAny WaitMode = "any"
+)
+
+// DefaultWaitInterval is the default delay between polls for Wait.
+var DefaultWaitInterval = 5 * time.Second
+
+// WaitOptions configures Wait.
+type WaitOptions struct {
+	// Mode controls how many of the ports are required to be open, defaults to WaitModeAll.
+	Mode WaitMode
+	// Interval is the delay between polls, defaults to DefaultWaitInterval.
+	Interval time.Duration
+	// TimeoutPerPort is the dial timeout for each poll of a port, defaults to DefaultTimeoutPerPort.
+	TimeoutPerPort time.Duration
+	// RateLimiters, HostRateLimiters and Jitter apply to each poll of a port,
+	// like they do to the probes of a scan.
+	RateLimiters     []*RateLimiter
+	HostRateLimiters []*HostRateLimiter
+	Jitter           time.Duration
+}
+
+// WaitError is returned by Wait when the context is done before the ports are open.
+type WaitError struct {
+	IP      string
+	Mode    WaitMode
+	Results map[int]PortScanResult
+	Err     error
+}
+
+// Error summarizes the last observed state of each port.
+func (e *WaitError) Error() string {
+	ports := []int{}
+	for port := range e.Results {
+		ports = append(ports, port)
+	}
+	sort.Ints(ports)
+
+	summary := []string{}
+	for _, port := range ports {
+		result := e.Results[port]
+		switch {
+		case result.Open:
+			summary = append(summary, fmt.Sprintf("%d: open", port))
+		case result.Error != nil:
+			summary = append(summary, fmt.Sprintf("%d: %s (%s)", port, result.State, result.Error))
+		default:
+			summary = append(summary, fmt.Sprintf("%d: not scanned", port))
+		}
+	}
+
+	return fmt.Sprintf("waiting for %s of the ports on %s to be open: %s\n\n%s", e.Mode, e.IP, e.Err, strings.Join(summary, "\n"))
+}
+
+// Unwrap returns the context error which stopped the wait.
+func (e *WaitError) Unwrap() error {
+	return e.Err
+}
+
+// Wait polls the ports on the given IP until all, or any, of them are open,
+// depending on the wait mode. Ports that are already open aren't polled again,
+// and each poll waits for the rate limiters and jitter first. If the context is done first, a *WaitError summarizing the last result for
+// each port is returned.
+func Wait(ctx context.Context, d Dialer, ip string, ports []int, opts *WaitOptions) (map[int]PortScanResult, error) {
+	var (
+		mode           = WaitModeAll
+		interval       = DefaultWaitInterval
+		timeoutPerPort = DefaultTimeoutPerPort
+	)
+
+	s := newScan(d, nil)
+
+	if opts != nil {
+		s = newScan(d, &Options{
+			RateLimiters:     opts.RateLimiters,
+			HostRateLimiters: opts.HostRateLimiters,
+			Jitter:           opts.Jitter,
+		})
+		if opts.Mode != "" {
+			mode = opts.Mode
+		}
+		if opts.Interval > 0 {
+			interval = opts.Interval
+		}
+		if opts.TimeoutPerPort > 0 {
+			timeoutPerPort = opts.TimeoutPerPort
+		}
+	}
+
+	results := map[int]PortScanResult{}
+	for _, port := range ports {
+		results[port] = PortScanResult{IP: ip, Port: port}
+	}
+
+	for {
+		var (
+			mu sync.Mutex
+			wg sync.WaitGroup
+		)
+
+		pending := []int{}
+		for port, result := range results {
+			if !result.Open {
+				pending = append(pending, port)
+			}
+		}
+
+		for _, port := range pending {
+			s.wait(ctx, ip)
+			if ctx.Err() != nil {
+				break
+			}
+			if err := controller.Acquire(ctx); err != nil {
+				break
+			}
+			wg.Add(1)
+			go func(port int) {
+				defer wg.Done()
+
+				timeout := timeoutPerPort
+				if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < timeout {
+					timeout = time.Until(deadline)
+				}
+
+				result := scanPort(d, ip, port, timeout)
+				controller.Release(classifyError(result.Error))
+
+				mu.Lock()
+				defer mu.Unlock()
+
+				// a dial cut short by the context says nothing about the
+				// port, so the last result is kept for the summary
+				if result.TimedOut() && ctx.Err() != nil && results[port].Attempts > 0 {
+					return
+				}
+
+				result.Attempts = results[port].Attempts + 1
+				results[port] = result
+			}(port)
+		}
+
+		wg.Wait()
+
+		if waitDone(mode, results) {
+			return results, nil
+		}
+
+		if err := sleep(ctx, interval); err != nil {
+			return results, &WaitError{IP: ip, Mode: mode, Results: results, Err: err}
+		}
+	}
+}
+
+// waitDone checks if the ports satisfy the wait mode.
+func waitDone(mode WaitMode, results map[int]PortScanResult) bool {
+	open := 0
+	for _, result := range results {
+		if result.Open {
+			open++
+		}
+	}
+
+	if mode == WaitModeAny {
+		return open > 0
+	}
+	return open == len(results)
+}
@@ -0,0 +1,111 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Wait(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	// start listening again after a few polls
+	go func() {
+		time.Sleep(150 * time.Millisecond)
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			t.Log(err)
+			return
+		}
+		time.Sleep(time.Second)
+		listener.Close()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	results, err := Wait(ctx, DefaultDialer, "127.0.0.1", []int{port}, &WaitOptions{Interval: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !results[port].Open {
+		t.Fatalf("Expected port %d to be open", port)
+	}
+	if results[port].Attempts < 2 {
+		t.Fatalf("Expected port %d to be polled more than once, got %d", port, results[port].Attempts)
+	}
+}
+
+func Test_Wait_timeout(t *testing.T) {
+	open, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer open.Close()
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed.Close()
+
+	ports := []int{
+		open.Addr().(*net.TCPAddr).Port,
+		closed.Addr().(*net.TCPAddr).Port,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	_, err = Wait(ctx, DefaultDialer, "127.0.0.1", ports, &WaitOptions{Interval: 50 * time.Millisecond})
+
+	var waitErr *WaitError
+	if !errors.As(err, &waitErr) {
+		t.Fatalf("Expected a *WaitError, got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the wait to stop because of the deadline, got %v", waitErr.Err)
+	}
+	if !strings.Contains(err.Error(), "closed") {
+		t.Fatalf("Expected the error to summarize the closed port, got %q", err.Error())
+	}
+
+	// waiting for any port is satisfied by the open one
+	results, err := Wait(context.Background(), DefaultDialer, "127.0.0.1", ports, &WaitOptions{Mode: WaitModeAny})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !results[ports[0]].Open {
+		t.Fatalf("Expected port %d to be open", ports[0])
+	}
+}
+
+func Test_Wait_rateLimited(t *testing.T) {
+	d := &scriptedDialer{script: []error{errScriptedRefused}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	_, err := Wait(ctx, d, "192.0.2.1", []int{22, 80}, &WaitOptions{
+		Interval:     time.Millisecond,
+		RateLimiters: []*RateLimiter{NewRateLimiter(20, 1)},
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the wait to stop because of the deadline, got %v", err)
+	}
+
+	// at 20 polls per second, with a burst of 1, at most 5 polls fit in 200ms
+	if d.dials > 5 {
+		t.Errorf("Expected the polls to be rate limited, got %d dials", d.dials)
+	}
+}
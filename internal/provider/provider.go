@@ -1,15 +1,224 @@
 package provider
 
 import (
+	"fmt"
+	"log"
+	"time"
+
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
 	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+	scanner "github.com/picatz/terraform-provider-port-scan/internal/provider/port-scanner"
 )
 
 // New provides a new Terraform resource provider schema.
 func New() terraform.ResourceProvider {
 	return &schema.Provider{
+		Schema: map[string]*schema.Schema{
+			"raise_open_file_limit": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Raise the soft open file limit towards the hard limit to allow more concurrent connections",
+			},
+			"rate_limit": {
+				Type:         schema.TypeFloat,
+				Optional:     true,
+				Default:      0,
+				ValidateFunc: validation.FloatAtLeast(0),
+				Description:  "Maximum connections per second across all scans, 0 is unlimited",
+			},
+			"host_rate_limit": {
+				Type:         schema.TypeFloat,
+				Optional:     true,
+				Default:      0,
+				ValidateFunc: validation.FloatAtLeast(0),
+				Description:  "Maximum connections per second to each target host across all scans, 0 is unlimited",
+			},
+			"jitter": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validateDuration,
+				Description:  "Upper bound of a random delay added before each probe, such as \"50ms\"",
+			},
+			"randomize_ports": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Scan ports in a random order",
+			},
+			"max_probes": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      0,
+				ValidateFunc: validation.IntAtLeast(0),
+				Description:  "Maximum number of ports a single scan may probe across all of its hosts, 0 is unlimited",
+			},
+			"cache_dir": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Directory to cache the results of port_scan data sources in, caching is disabled when unset",
+			},
+			"cache_ttl": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "5m",
+				ValidateFunc: validateDuration,
+				Description:  "How long cached results are reused for, unless overridden by the data source",
+			},
+		},
 		DataSourcesMap: map[string]*schema.Resource{
-			"port_scan": dataSourcePortScan(),
+			"port_scan":                dataSourcePortScan(),
+			"port_scan_hosts":          dataSourcePortScanHosts(),
+			"port_scan_host_discovery": dataSourcePortScanHostDiscovery(),
+			"port_scan_nmap_report":    dataSourcePortScanNmapReport(),
+			"port_scan_diff":           dataSourcePortScanDiff(),
+			"port_scan_policy":         dataSourcePortScanPolicy(),
+			"port_scan_firewall":       dataSourcePortScanFirewall(),
 		},
+		ResourcesMap: map[string]*schema.Resource{
+			"port_scan_monitor": resourcePortScanMonitor(),
+			"port_scan_wait":    resourcePortScanWait(),
+		},
+		ConfigureFunc: providerConfigure,
+	}
+}
+
+// providerConfig holds the provider-level settings shared by all scans.
+type providerConfig struct {
+	rateLimiter     *scanner.RateLimiter
+	hostRateLimiter *scanner.HostRateLimiter
+	jitter          time.Duration
+	randomizePorts  bool
+	maxProbes       int
+	cache           *resultCache
+}
+
+func providerConfigure(d *schema.ResourceData) (interface{}, error) {
+	if d.Get("raise_open_file_limit").(bool) {
+		limit, err := scanner.RaiseOpenFileLimit()
+		if err != nil {
+			log.Printf("[WARN] unable to raise open file limit: %s", err)
+		} else {
+			log.Printf("[DEBUG] open file limit is now %d", limit)
+		}
+	}
+
+	config := &providerConfig{
+		randomizePorts: d.Get("randomize_ports").(bool),
+		maxProbes:      d.Get("max_probes").(int),
+	}
+
+	if rate := d.Get("rate_limit").(float64); rate > 0 {
+		config.rateLimiter = scanner.NewRateLimiter(rate, 1)
+	}
+
+	if rate := d.Get("host_rate_limit").(float64); rate > 0 {
+		config.hostRateLimiter = scanner.NewHostRateLimiter(rate, 1)
 	}
+
+	if v, ok := d.GetOk("jitter"); ok {
+		config.jitter, _ = time.ParseDuration(v.(string))
+	}
+
+	if v, ok := d.GetOk("cache_dir"); ok {
+		ttl, _ := time.ParseDuration(d.Get("cache_ttl").(string))
+
+		cache, err := newResultCache(v.(string), ttl)
+		if err != nil {
+			return nil, err
+		}
+		config.cache = cache
+	}
+
+	return config, nil
+}
+
+// scanOptions builds the scanner options for a data source or resource,
+// combining the provider-level settings with its own scan settings. Only
+// the scan arguments declared in its schema are used, the rest keep their
+// default values.
+func (c *providerConfig) scanOptions(d *schema.ResourceData) *scanner.Options {
+	opts := &scanner.Options{
+		TimeoutPerPort: scanner.DefaultTimeoutPerPort,
+		Jitter:         c.jitter,
+		RandomizePorts: c.randomizePorts,
+	}
+
+	if v, ok := d.GetOk("timeout_per_port"); ok {
+		opts.TimeoutPerPort, _ = time.ParseDuration(v.(string))
+	}
+	if v, ok := d.GetOk("timeout_mode"); ok {
+		opts.TimeoutMode = scanner.TimeoutMode(v.(string))
+	}
+
+	if v, ok := d.GetOk("retry_attempts"); ok {
+		opts.Retry.Attempts = v.(int)
+	}
+	if v, ok := d.GetOk("retry_backoff"); ok {
+		opts.Retry.Backoff, _ = time.ParseDuration(v.(string))
+	}
+	if v, ok := d.GetOk("confirmations"); ok {
+		opts.Confirmations = v.(int)
+	}
+
+	if c.rateLimiter != nil {
+		opts.RateLimiters = append(opts.RateLimiters, c.rateLimiter)
+	}
+	if v, ok := d.GetOk("rate_limit"); ok {
+		opts.RateLimiters = append(opts.RateLimiters, scanner.NewRateLimiter(v.(float64), 1))
+	}
+
+	if c.hostRateLimiter != nil {
+		opts.HostRateLimiters = append(opts.HostRateLimiters, c.hostRateLimiter)
+	}
+	if v, ok := d.GetOk("host_rate_limit"); ok {
+		opts.HostRateLimiters = append(opts.HostRateLimiters, scanner.NewHostRateLimiter(v.(float64), 1))
+	}
+
+	if v, ok := d.GetOk("jitter"); ok {
+		opts.Jitter, _ = time.ParseDuration(v.(string))
+	}
+	if v, ok := d.GetOk("randomize_ports"); ok && v.(bool) {
+		opts.RandomizePorts = true
+	}
+
+	if v, ok := d.GetOk("grab_banners"); ok {
+		opts.GrabBanners = v.(bool)
+	}
+	if v, ok := d.GetOk("banner_timeout"); ok {
+		opts.BannerTimeout, _ = time.ParseDuration(v.(string))
+	}
+	if v, ok := d.GetOk("probers"); ok {
+		for _, name := range v.([]interface{}) {
+			if name != nil {
+				opts.Probers = append(opts.Probers, name.(string))
+			}
+		}
+	}
+	if v, ok := d.GetOk("probe_timeout"); ok {
+		opts.ProbeTimeout, _ = time.ParseDuration(v.(string))
+	}
+
+	return opts
+}
+
+func validateDuration(i interface{}, k string) (warnings []string, errors []error) {
+	v, ok := i.(string)
+	if !ok {
+		errors = append(errors, fmt.Errorf("expected type of %q to be string", k))
+		return
+	}
+
+	duration, err := time.ParseDuration(v)
+	if err != nil {
+		errors = append(errors, fmt.Errorf("expected %q to be a duration such as \"5s\", got %q: %s", k, v, err))
+		return
+	}
+
+	if duration < 0 {
+		errors = append(errors, fmt.Errorf("expected %q to not be negative, got %q", k, v))
+	}
+
+	return
 }
@@ -22,3 +22,12 @@ func TestNew(t *testing.T) {
 		t.Fatalf("err: %s", err)
 	}
 }
+
+func TestProvider_validate(t *testing.T) {
+	for _, key := range []string{"rate_limit", "host_rate_limit"} {
+		_, errs := New().(*schema.Provider).Validate(terraform.NewResourceConfigRaw(map[string]interface{}{key: -1.0}))
+		if len(errs) == 0 {
+			t.Errorf("Expected a negative %s to be invalid", key)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package provider
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
+	scanner "github.com/picatz/terraform-provider-port-scan/internal/provider/port-scanner"
+)
+
+// Formats of the report_file written by the data sources.
+const (
+	reportFormatJSON     = "json"
+	reportFormatXML      = "xml"
+	reportFormatGrepable = "grepable"
+)
+
+// reportWriters write a report in each of the report formats.
+var reportWriters = map[string]func(io.Writer, *scanner.Report) error{
+	reportFormatJSON:     scanner.WriteJSON,
+	reportFormatXML:      scanner.WriteNmapXML,
+	reportFormatGrepable: scanner.WriteNmapGrepable,
+}
+
+// reportFileSchema is the optional path a report of the scan is written to.
+func reportFileSchema() *schema.Schema {
+	return &schema.Schema{
+		ForceNew:    true,
+		Optional:    true,
+		Type:        schema.TypeString,
+		Description: "Path of a file the scan report is written to, in the report_format",
+	}
+}
+
+// reportFormatSchema is the format of the report_file.
+func reportFormatSchema() *schema.Schema {
+	return &schema.Schema{
+		ForceNew:     true,
+		Optional:     true,
+		Type:         schema.TypeString,
+		Default:      reportFormatXML,
+		ValidateFunc: validation.StringInSlice([]string{reportFormatJSON, reportFormatXML, reportFormatGrepable}, false),
+		Description:  "Format of the report_file, either nmap compatible \"xml\" or \"grepable\" output, or \"json\"",
+	}
+}
+
+// writeReportFile writes the report of the scan to the report_file, when
+// it's set.
+func writeReportFile(d *schema.ResourceData, start time.Time, duration time.Duration, results []scanner.PortScanResult) error {
+	v, ok := d.GetOk("report_file")
+	if !ok {
+		return nil
+	}
+	path := v.(string)
+
+	report := &scanner.Report{
+		StartedAt: start.UTC(),
+		Duration:  duration,
+		Dialer:    dialerType(d),
+		Results:   results,
+	}
+
+	buf := &bytes.Buffer{}
+	if err := reportWriters[d.Get("report_format").(string)](buf, report); err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(path, buf.Bytes()); err != nil {
+		return fmt.Errorf("writing report_file: %s", err)
+	}
+	return nil
+}
+
+// sarifFileSchema is the optional path the exposures found by the scan are
+// written to as SARIF.
+func sarifFileSchema() *schema.Schema {
+	return &schema.Schema{
+		ForceNew:    true,
+		Optional:    true,
+		Type:        schema.TypeString,
+		Description: "Path of a SARIF file the unexpected open ports are written to",
+	}
+}
+
+// exposurePolicy returns the policy of the sarif_file findings, which is the
+// same as the one of the port-scan command: ports listed in
+// expected_closed_ports are forbidden, and every open port that isn't listed
+// in expected_open_ports is an exposure. Suppressed findings stay suppressed.
+func exposurePolicy(d *schema.ResourceData) *scanner.ExposurePolicy {
+	expectedOpen, expectedClosed := expectedPorts(d)
+	return &scanner.ExposurePolicy{
+		AllowedPorts:   expectedOpen,
+		ForbiddenPorts: expectedClosed,
+		Suppressions:   suppressions(d),
+	}
+}
+
+// writeSARIFFile writes the exposures of the exposurePolicy to the sarif_file
+// as findings, when it's set.
+func writeSARIFFile(d *schema.ResourceData, results []scanner.PortScanResult) error {
+	v, ok := d.GetOk("sarif_file")
+	if !ok {
+		return nil
+	}
+
+	policy := exposurePolicy(d)
+
+	buf := &bytes.Buffer{}
+	if err := scanner.WriteSARIF(buf, &scanner.Report{Results: results}, policy); err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(v.(string), buf.Bytes()); err != nil {
+		return fmt.Errorf("writing sarif_file: %s", err)
+	}
+	return nil
+}
+
+// writeFileAtomic replaces the file atomically, so tools watching it never
+// see partial content.
+func writeFileAtomic(path string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	// temporary files are only readable by their owner
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
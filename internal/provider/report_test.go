@@ -0,0 +1,134 @@
+package provider
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	scanner "github.com/picatz/terraform-provider-port-scan/internal/provider/port-scanner"
+)
+
+func Test_dataSourcePortScanRead_reportFile(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	dir, err := ioutil.TempDir("", "port-scan-report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for format, want := range map[string]string{
+		reportFormatXML:      `<state state="open" reason="syn-ack">`,
+		reportFormatGrepable: "/open/tcp//",
+		reportFormatJSON:     `"state": "open"`,
+	} {
+		path := filepath.Join(dir, "report."+format)
+
+		d := schema.TestResourceDataRaw(t, dataSourcePortScan().Schema, map[string]interface{}{
+			"ip_address":    "127.0.0.1",
+			"port":          listener.Addr().(*net.TCPAddr).Port,
+			"report_file":   path,
+			"report_format": format,
+		})
+		if err := dataSourcePortScanRead(d, &providerConfig{}); err != nil {
+			t.Fatal(err)
+		}
+
+		report, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(report), want) {
+			t.Errorf("Expected the %s report to contain %q, got:\n%s", format, want, report)
+		}
+	}
+}
+
+func Test_dataSourcePortScanRead_sarifFile(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	dir, err := ioutil.TempDir("", "port-scan-sarif")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "port-scan.sarif")
+
+	d := schema.TestResourceDataRaw(t, dataSourcePortScan().Schema, map[string]interface{}{
+		"ip_address":            "127.0.0.1",
+		"port":                  port,
+		"expected_closed_ports": []interface{}{port},
+		"sarif_file":            path,
+	})
+	if err := dataSourcePortScanRead(d, &providerConfig{}); err != nil {
+		t.Fatal(err)
+	}
+
+	sarif, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(sarif), `"level": "error"`) || !strings.Contains(string(sarif), fmt.Sprintf(`"uri": "tcp://127.0.0.1:%d"`, port)) {
+		t.Errorf("Expected a finding for the forbidden open port, got:\n%s", sarif)
+	}
+
+	// without expected ports, every open port is a finding, like with the port-scan command
+	d = schema.TestResourceDataRaw(t, dataSourcePortScan().Schema, map[string]interface{}{
+		"ip_address": "127.0.0.1",
+		"port":       port,
+		"sarif_file": path,
+	})
+	if err := dataSourcePortScanRead(d, &providerConfig{}); err != nil {
+		t.Fatal(err)
+	}
+
+	sarif, err = ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(sarif), `"level": "warning"`) || !strings.Contains(string(sarif), fmt.Sprintf(`"uri": "tcp://127.0.0.1:%d"`, port)) {
+		t.Errorf("Expected a finding for the open port, got:\n%s", sarif)
+	}
+}
+
+func Test_exposurePolicy(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, dataSourcePortScan().Schema, map[string]interface{}{
+		"ip_address":          "127.0.0.1",
+		"expected_open_ports": []interface{}{443},
+		"suppression": []interface{}{
+			map[string]interface{}{"id": "rdp", "reason": "only reachable over the VPN"},
+		},
+	})
+
+	exposures := exposurePolicy(d).Exposures([]scanner.PortScanResult{
+		{IP: "10.0.0.1", Port: 443, Open: true, State: scanner.PortStateOpen},
+		{IP: "10.0.0.1", Port: 3389, Open: true, State: scanner.PortStateOpen},
+		{IP: "10.0.0.1", Port: 6379, Open: true, State: scanner.PortStateOpen},
+	})
+
+	if len(exposures) != 2 {
+		t.Fatalf("Expected the ports that aren't expected to be open to be exposures, got %+v", exposures)
+	}
+	if !exposures[0].Suppressed || exposures[0].SuppressionReason != "only reachable over the VPN" {
+		t.Errorf("Expected the RDP exposure to be suppressed, got %+v", exposures[0])
+	}
+	if exposures[1].Suppressed || exposures[1].Severity != scanner.SeverityHigh {
+		t.Errorf("Expected a high severity Redis exposure, got %+v", exposures[1])
+	}
+}
@@ -0,0 +1,266 @@
+package provider
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
+	scanner "github.com/picatz/terraform-provider-port-scan/internal/provider/port-scanner"
+)
+
+// resourcePortScanMonitor stores the acknowledged open ports in state, and
+// re-scans on every refresh. When the open ports observed during the refresh
+// differ from the acknowledged ones, the plan shows an in-place update, so
+// newly opened (or closed) ports are visible in terraform plan.
+func resourcePortScanMonitor() *schema.Resource {
+	return &schema.Resource{
+		Create:        resourcePortScanMonitorCreate,
+		Read:          resourcePortScanMonitorRead,
+		Update:        resourcePortScanMonitorUpdate,
+		Delete:        resourcePortScanMonitorDelete,
+		CustomizeDiff: resourcePortScanMonitorCustomizeDiff,
+		Schema: map[string]*schema.Schema{
+			"ip_address": {
+				ForceNew:     true,
+				Required:     true,
+				Type:         schema.TypeString,
+				ValidateFunc: validateHost,
+			},
+			"port": {
+				ForceNew:      true,
+				Optional:      true,
+				Type:          schema.TypeInt,
+				ValidateFunc:  validation.IsPortNumber,
+				ConflictsWith: []string{"ports", "from_port", "to_port"},
+			},
+			"ports": {
+				ForceNew:      true,
+				Optional:      true,
+				Type:          schema.TypeList,
+				MinItems:      1,
+				ConflictsWith: []string{"port", "from_port", "to_port"},
+				Elem: &schema.Schema{
+					Type:         schema.TypeInt,
+					ValidateFunc: validation.IsPortNumber,
+				},
+			},
+			"from_port": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeInt,
+				Default:      1,
+				ValidateFunc: validation.IsPortNumber,
+			},
+			"to_port": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeInt,
+				Default:      1024,
+				ValidateFunc: validation.IsPortNumber,
+			},
+			"triggers": {
+				Optional:    true,
+				Type:        schema.TypeMap,
+				Description: "Arbitrary values that force a re-scan when changed",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"history_size": {
+				Optional:     true,
+				Type:         schema.TypeInt,
+				Default:      10,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "Number of past observations kept in the history",
+			},
+			// Optional scan controls, used to avoid drift from flapping ports
+			"timeout_per_port": {
+				Optional:     true,
+				Type:         schema.TypeString,
+				Default:      scanner.DefaultTimeoutPerPort.String(),
+				ValidateFunc: validateDuration,
+				Description:  "Dial timeout for each port",
+			},
+			"retry_attempts": {
+				Optional:     true,
+				Type:         schema.TypeInt,
+				Default:      1,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "Total number of attempts for ports that time out, including the first one",
+			},
+			"confirmations": {
+				Optional:     true,
+				Type:         schema.TypeInt,
+				Default:      1,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "Number of consecutive consistent results required before the state of a port is reported",
+			},
+			"ssh_bastion": sshBastionSchema(),
+			// Computed fields
+			"open_ports": {
+				Computed:    true,
+				Type:        schema.TypeList,
+				Description: "Open ports acknowledged by the last apply",
+				Elem: &schema.Schema{
+					Type: schema.TypeInt,
+				},
+			},
+			"observed_open_ports": {
+				Computed:    true,
+				Type:        schema.TypeList,
+				Description: "Open ports observed by the last scan",
+				Elem: &schema.Schema{
+					Type: schema.TypeInt,
+				},
+			},
+			"observed_at": {
+				Computed:    true,
+				Type:        schema.TypeString,
+				Description: "RFC 3339 timestamp of the last scan",
+			},
+			"history": {
+				Computed:    true,
+				Type:        schema.TypeList,
+				Description: "Past acknowledged observations, oldest first",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"observed_at": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"open_ports": {
+							Type:     schema.TypeList,
+							Computed: true,
+							Elem: &schema.Schema{
+								Type: schema.TypeInt,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// resourcePortScanMonitorObserve scans the ports, setting the observed open ports.
+func resourcePortScanMonitorObserve(d *schema.ResourceData, meta interface{}) ([]int, string, error) {
+	results, err := boundedScan(d, meta, []string{d.Get("ip_address").(string)}, portsToScan(d))
+	if err != nil {
+		return nil, "", err
+	}
+
+	open := openPorts(results)
+	observedAt := time.Now().UTC().Format(time.RFC3339)
+
+	if err := d.Set("observed_open_ports", open); err != nil {
+		return nil, "", err
+	}
+	if err := d.Set("observed_at", observedAt); err != nil {
+		return nil, "", err
+	}
+
+	return open, observedAt, nil
+}
+
+// resourcePortScanMonitorRecord acknowledges the open ports, adding them to the history.
+func resourcePortScanMonitorRecord(d *schema.ResourceData, open []int, observedAt string) error {
+	history := d.Get("history").([]interface{})
+	history = append(history, map[string]interface{}{
+		"observed_at": observedAt,
+		"open_ports":  open,
+	})
+
+	if err := d.Set("open_ports", open); err != nil {
+		return err
+	}
+	return d.Set("history", trimHistory(history, d.Get("history_size").(int)))
+}
+
+// trimHistory keeps the newest size entries of the history.
+func trimHistory(history []interface{}, size int) []interface{} {
+	if len(history) > size {
+		history = history[len(history)-size:]
+	}
+	return history
+}
+
+func resourcePortScanMonitorCreate(d *schema.ResourceData, meta interface{}) error {
+	open, observedAt, err := resourcePortScanMonitorObserve(d, meta)
+	if err != nil {
+		return err
+	}
+
+	d.SetId(resource.UniqueId())
+
+	return resourcePortScanMonitorRecord(d, open, observedAt)
+}
+
+// resourcePortScanMonitorRead re-scans the ports on every refresh, only
+// updating the observed open ports so the difference shows up in the plan.
+func resourcePortScanMonitorRead(d *schema.ResourceData, meta interface{}) error {
+	_, _, err := resourcePortScanMonitorObserve(d, meta)
+	return err
+}
+
+func resourcePortScanMonitorUpdate(d *schema.ResourceData, meta interface{}) error {
+	if d.HasChange("triggers") {
+		open, observedAt, err := resourcePortScanMonitorObserve(d, meta)
+		if err != nil {
+			return err
+		}
+		return resourcePortScanMonitorRecord(d, open, observedAt)
+	}
+
+	if d.HasChange("open_ports") {
+		return resourcePortScanMonitorRecord(d, convertIntArr(d.Get("open_ports").([]interface{})), d.Get("observed_at").(string))
+	}
+
+	return d.Set("history", trimHistory(d.Get("history").([]interface{}), d.Get("history_size").(int)))
+}
+
+func resourcePortScanMonitorDelete(d *schema.ResourceData, meta interface{}) error {
+	d.SetId("")
+	return nil
+}
+
+// resourcePortScanMonitorCustomizeDiff plans an in-place update when the open
+// ports observed during the refresh differ from the acknowledged ones, or a
+// re-scan when the triggers change.
+func resourcePortScanMonitorCustomizeDiff(d *schema.ResourceDiff, meta interface{}) error {
+	if err := customizeDiffPorts(d, meta); err != nil {
+		return err
+	}
+
+	if d.Id() == "" {
+		return nil
+	}
+
+	if d.HasChange("triggers") {
+		for _, key := range []string{"open_ports", "observed_open_ports", "observed_at", "history"} {
+			if err := d.SetNewComputed(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var (
+		open     = convertIntArr(d.Get("open_ports").([]interface{}))
+		observed = convertIntArr(d.Get("observed_open_ports").([]interface{}))
+	)
+
+	if !reflect.DeepEqual(open, observed) {
+		if err := d.SetNew("open_ports", observed); err != nil {
+			return err
+		}
+		return d.SetNewComputed("history")
+	}
+
+	if d.HasChange("history_size") {
+		return d.SetNewComputed("history")
+	}
+
+	return nil
+}
@@ -0,0 +1,141 @@
+package provider
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+)
+
+func Test_trimHistory(t *testing.T) {
+	history := []interface{}{"a", "b", "c"}
+
+	if trimmed := trimHistory(history, 2); !reflect.DeepEqual(trimmed, []interface{}{"b", "c"}) {
+		t.Errorf("Expected the oldest entry to be dropped, got %v", trimmed)
+	}
+
+	if trimmed := trimHistory(history, 5); !reflect.DeepEqual(trimmed, history) {
+		t.Errorf("Expected the history to be unchanged, got %v", trimmed)
+	}
+}
+
+// monitorState returns the state of a monitor created with the config, which
+// acknowledged the open ports, and observed the other open ports since.
+func monitorState(t *testing.T, config map[string]interface{}, open, observed []int) *terraform.InstanceState {
+	d := schema.TestResourceDataRaw(t, resourcePortScanMonitor().Schema, config)
+	d.SetId("127.0.0.1")
+
+	if err := d.Set("open_ports", open); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Set("observed_open_ports", observed); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Set("observed_at", "2020-01-01T00:00:00Z"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Set("history", []interface{}{
+		map[string]interface{}{"observed_at": "2020-01-01T00:00:00Z", "open_ports": open},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	return d.State()
+}
+
+func Test_resourcePortScanMonitorCustomizeDiff_drift(t *testing.T) {
+	r := resourcePortScanMonitor()
+	meta := &providerConfig{}
+
+	config := map[string]interface{}{
+		"ip_address": "127.0.0.1",
+		"ports":      []interface{}{22, 80, 443},
+		"triggers":   map[string]interface{}{"version": "1"},
+	}
+
+	// no drift, no changes
+	state := monitorState(t, config, []int{22, 443}, []int{22, 443})
+	diff, err := r.Diff(state, terraform.NewResourceConfigRaw(config), meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.Empty() {
+		t.Errorf("Expected no changes without drift, got %v", diff.Attributes)
+	}
+
+	// port 80 opened since the last apply
+	state = monitorState(t, config, []int{22, 443}, []int{22, 80, 443})
+	diff, err = r.Diff(state, terraform.NewResourceConfigRaw(config), meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.Empty() {
+		t.Fatal("Expected an update when the observed open ports drifted")
+	}
+	if diff.RequiresNew() {
+		t.Errorf("Expected drift to be an in-place update, got %v", diff.Attributes)
+	}
+	if attr := diff.Attributes["open_ports.1"]; attr == nil || attr.Old != "443" || attr.New != "80" {
+		t.Errorf("Expected open_ports to be updated to the observed open ports, got %v", diff.Attributes)
+	}
+	if attr := diff.Attributes["open_ports.#"]; attr == nil || attr.New != "3" {
+		t.Errorf("Expected 3 open ports to be planned, got %v", diff.Attributes)
+	}
+	if attr := diff.Attributes["history.#"]; attr == nil || !attr.NewComputed {
+		t.Errorf("Expected the history to be recomputed, got %v", diff.Attributes)
+	}
+}
+
+func Test_resourcePortScanMonitorCustomizeDiff_triggers(t *testing.T) {
+	r := resourcePortScanMonitor()
+	meta := &providerConfig{}
+
+	config := map[string]interface{}{
+		"ip_address": "127.0.0.1",
+		"ports":      []interface{}{22},
+		"triggers":   map[string]interface{}{"version": "1"},
+	}
+
+	state := monitorState(t, config, []int{22}, []int{22})
+
+	config["triggers"] = map[string]interface{}{"version": "2"}
+
+	diff, err := r.Diff(state, terraform.NewResourceConfigRaw(config), meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.RequiresNew() {
+		t.Errorf("Expected changed triggers to re-scan in place, got %v", diff.Attributes)
+	}
+	for _, key := range []string{"open_ports.#", "observed_open_ports.#", "observed_at"} {
+		if attr := diff.Attributes[key]; attr == nil || !attr.NewComputed {
+			t.Errorf("Expected %s to be recomputed when the triggers change, got %v", key, diff.Attributes[key])
+		}
+	}
+}
+
+func Test_resourcePortScanMonitorCustomizeDiff_historySize(t *testing.T) {
+	r := resourcePortScanMonitor()
+	meta := &providerConfig{}
+
+	config := map[string]interface{}{
+		"ip_address": "127.0.0.1",
+		"port":       22,
+	}
+
+	state := monitorState(t, config, []int{22}, []int{22})
+
+	config["history_size"] = 3
+
+	diff, err := r.Diff(state, terraform.NewResourceConfigRaw(config), meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attr := diff.Attributes["history.#"]; attr == nil || !attr.NewComputed {
+		t.Errorf("Expected the history to be recomputed, got %v", diff.Attributes)
+	}
+	if attr := diff.Attributes["open_ports.#"]; attr != nil {
+		t.Errorf("Expected the open ports to be unchanged, got %v", attr)
+	}
+}
@@ -0,0 +1,164 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
+	scanner "github.com/picatz/terraform-provider-port-scan/internal/provider/port-scanner"
+)
+
+func resourcePortScanWait() *schema.Resource {
+	return &schema.Resource{
+		Create:        resourcePortScanWaitCreate,
+		Read:          resourcePortScanWaitRead,
+		Delete:        resourcePortScanWaitDelete,
+		CustomizeDiff: resourcePortScanWaitCustomizeDiff,
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(10 * time.Minute),
+		},
+		Schema: map[string]*schema.Schema{
+			"ip_address": {
+				ForceNew:     true,
+				Required:     true,
+				Type:         schema.TypeString,
+				ValidateFunc: validateHost,
+			},
+			"port": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeInt,
+				ValidateFunc: validation.IsPortNumber,
+				AtLeastOneOf: []string{"port", "ports"},
+			},
+			"ports": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeList,
+				AtLeastOneOf: []string{"port", "ports"},
+				Elem: &schema.Schema{
+					Type:         schema.TypeInt,
+					ValidateFunc: validation.IsPortNumber,
+				},
+			},
+			"mode": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeString,
+				Default:      string(scanner.WaitModeAll),
+				ValidateFunc: validation.StringInSlice([]string{string(scanner.WaitModeAll), string(scanner.WaitModeAny)}, false),
+				Description:  "Either \"all\" to wait for all of the ports to be open, or \"any\" to wait for any of them",
+			},
+			"interval": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeString,
+				Default:      scanner.DefaultWaitInterval.String(),
+				ValidateFunc: validateDuration,
+				Description:  "Delay between polls of the ports",
+			},
+			"timeout_per_port": {
+				ForceNew:     true,
+				Optional:     true,
+				Type:         schema.TypeString,
+				Default:      scanner.DefaultTimeoutPerPort.String(),
+				ValidateFunc: validateDuration,
+				Description:  "Dial timeout for each poll of a port",
+			},
+			"ssh_bastion": sshBastionSchema(),
+			// Computed fields
+			"open_ports": {
+				Computed: true,
+				Type:     schema.TypeList,
+				Elem: &schema.Schema{
+					Type: schema.TypeInt,
+				},
+			},
+		},
+	}
+}
+
+func resourcePortScanWaitCreate(d *schema.ResourceData, meta interface{}) error {
+	ipAddress := d.Get("ip_address").(string)
+
+	ports := waitPorts(d)
+	if len(ports) == 0 {
+		return fmt.Errorf("one of port or ports must be provided")
+	}
+
+	// the polls are rate limited like the scans of the provider
+	scanOpts := meta.(*providerConfig).scanOptions(d)
+
+	opts := &scanner.WaitOptions{
+		Mode:             scanner.WaitMode(d.Get("mode").(string)),
+		RateLimiters:     scanOpts.RateLimiters,
+		HostRateLimiters: scanOpts.HostRateLimiters,
+		Jitter:           scanOpts.Jitter,
+	}
+	opts.Interval, _ = time.ParseDuration(d.Get("interval").(string))
+	opts.TimeoutPerPort, _ = time.ParseDuration(d.Get("timeout_per_port").(string))
+
+	dialer, err := newDialer(d)
+	if err != nil {
+		return err
+	}
+	defer dialer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), d.Timeout(schema.TimeoutCreate))
+	defer cancel()
+
+	results, err := scanner.Wait(ctx, dialer, ipAddress, ports, opts)
+	if err != nil {
+		return err
+	}
+
+	scanned := []scanner.PortScanResult{}
+	for _, result := range results {
+		scanned = append(scanned, result)
+	}
+	sortResults(scanned)
+
+	ids := []string{}
+	for _, port := range ports {
+		ids = append(ids, strconv.Itoa(port))
+	}
+	d.SetId(fmt.Sprintf("%s:%s", ipAddress, strings.Join(ids, ",")))
+
+	return d.Set("open_ports", openPorts(scanned))
+}
+
+// waitPorts returns the ports configured with port and ports combined.
+func waitPorts(d resourceGetter) []int {
+	ports := []int{}
+	if port, ok := d.GetOk("port"); ok {
+		ports = append(ports, port.(int))
+	}
+	if portsConfig, ok := d.GetOk("ports"); ok {
+		ports = append(ports, convertIntArr(portsConfig.([]interface{}))...)
+	}
+	return ports
+}
+
+// resourcePortScanWaitCustomizeDiff checks the number of ports polled
+// against the provider's max_probes limit.
+func resourcePortScanWaitCustomizeDiff(d *schema.ResourceDiff, meta interface{}) error {
+	if !d.NewValueKnown("port") || !d.NewValueKnown("ports") {
+		return nil
+	}
+	return meta.(*providerConfig).validateProbes(1, len(waitPorts(d)))
+}
+
+// resourcePortScanWaitRead keeps the state as is, since the wait has already
+// been satisfied when the resource was created.
+func resourcePortScanWaitRead(d *schema.ResourceData, meta interface{}) error {
+	return nil
+}
+
+func resourcePortScanWaitDelete(d *schema.ResourceData, meta interface{}) error {
+	d.SetId("")
+	return nil
+}
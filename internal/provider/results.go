@@ -0,0 +1,221 @@
+package provider
+
+import (
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	scanner "github.com/picatz/terraform-provider-port-scan/internal/provider/port-scanner"
+)
+
+// sortResults sorts scan results by IP address, then port.
+func sortResults(results []scanner.PortScanResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].IP != results[j].IP {
+			return results[i].IP < results[j].IP
+		}
+		return results[i].Port < results[j].Port
+	})
+}
+
+// openPorts returns the open ports from the scan results.
+func openPorts(results []scanner.PortScanResult) []int {
+	ports := []int{}
+	for _, result := range results {
+		if result.Open {
+			ports = append(ports, result.Port)
+		}
+	}
+	return ports
+}
+
+// openEndpoints returns the open ports from the scan results as "ip:port" endpoints.
+func openEndpoints(results []scanner.PortScanResult) []string {
+	endpoints := []string{}
+	for _, result := range results {
+		if result.Open {
+			endpoints = append(endpoints, net.JoinHostPort(result.IP, strconv.Itoa(result.Port)))
+		}
+	}
+	return endpoints
+}
+
+// resultObjects converts the scan results to the objects of the results
+// attribute. Only TCP connect scans are supported, so the protocol is always
+// "tcp".
+func resultObjects(results []scanner.PortScanResult) []interface{} {
+	objects := []interface{}{}
+	for _, result := range results {
+		errorMessage := ""
+		if result.Error != nil {
+			errorMessage = result.Error.Error()
+		}
+
+		probes := []interface{}{}
+		for _, finding := range result.Findings {
+			probes = append(probes, map[string]interface{}{
+				"prober": finding.Prober,
+				"name":   finding.Name,
+				"value":  finding.Value,
+			})
+		}
+
+		objects = append(objects, map[string]interface{}{
+			"ip_address": result.IP,
+			"port":       result.Port,
+			"protocol":   "tcp",
+			"state":      string(result.State),
+			"latency_ms": milliseconds(result.Latency),
+			"error":      errorMessage,
+			"service":    result.ServiceName(),
+			"banner":     result.Banner,
+			"probes":     probes,
+		})
+	}
+	return objects
+}
+
+// resultsSchema is the computed result for each scanned port.
+func resultsSchema() *schema.Schema {
+	return &schema.Schema{
+		Computed:    true,
+		Type:        schema.TypeList,
+		Description: "Result for each scanned port",
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"ip_address": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+				"port": {
+					Type:     schema.TypeInt,
+					Computed: true,
+				},
+				"protocol": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+				"state": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+				"latency_ms": {
+					Type:     schema.TypeFloat,
+					Computed: true,
+				},
+				"error": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+				"service": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+				"banner": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+				"probes": {
+					Type:        schema.TypeList,
+					Computed:    true,
+					Description: "Findings of the probers run on the port",
+					Elem: &schema.Resource{
+						Schema: map[string]*schema.Schema{
+							"prober": {
+								Type:     schema.TypeString,
+								Computed: true,
+							},
+							"name": {
+								Type:     schema.TypeString,
+								Computed: true,
+							},
+							"value": {
+								Type:     schema.TypeString,
+								Computed: true,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// setResults sets the results and open_endpoints attributes.
+func setResults(d *schema.ResourceData, results []scanner.PortScanResult) error {
+	if err := d.Set("results", resultObjects(results)); err != nil {
+		return err
+	}
+	return d.Set("open_endpoints", openEndpoints(results))
+}
+
+// milliseconds converts a duration to fractional milliseconds.
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// latencyStats returns the minimum, average and 95th percentile (nearest rank)
+// of the given latencies in milliseconds.
+func latencyStats(latencies []time.Duration) (min, avg, p95 float64) {
+	if len(latencies) == 0 {
+		return 0, 0, 0
+	}
+
+	sorted := append([]time.Duration{}, latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, latency := range sorted {
+		total += latency
+	}
+
+	rank := int(math.Ceil(0.95*float64(len(sorted)))) - 1
+
+	return milliseconds(sorted[0]), milliseconds(total) / float64(len(sorted)), milliseconds(sorted[rank])
+}
+
+// setLatencies sets the latency and TCP_INFO attributes for the open ports.
+func setLatencies(d *schema.ResourceData, results []scanner.PortScanResult) error {
+	var (
+		latencies   = []time.Duration{}
+		latenciesMs = map[string]interface{}{}
+		tcpInfos    = []interface{}{}
+	)
+
+	for _, result := range results {
+		if !result.Open {
+			continue
+		}
+
+		latencies = append(latencies, result.Latency)
+		latenciesMs[strconv.Itoa(result.Port)] = milliseconds(result.Latency)
+
+		if result.TCPInfo != nil {
+			tcpInfos = append(tcpInfos, map[string]interface{}{
+				"port":        result.Port,
+				"rtt_ms":      milliseconds(result.TCPInfo.RTT),
+				"rtt_var_ms":  milliseconds(result.TCPInfo.RTTVar),
+				"retransmits": int(result.TCPInfo.Retransmits),
+			})
+		}
+	}
+
+	min, avg, p95 := latencyStats(latencies)
+
+	if err := d.Set("latency_ms", latenciesMs); err != nil {
+		return err
+	}
+	if err := d.Set("latency_min_ms", min); err != nil {
+		return err
+	}
+	if err := d.Set("latency_avg_ms", avg); err != nil {
+		return err
+	}
+	if err := d.Set("latency_p95_ms", p95); err != nil {
+		return err
+	}
+	return d.Set("tcp_info", tcpInfos)
+}
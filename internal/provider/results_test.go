@@ -0,0 +1,78 @@
+package provider
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	scanner "github.com/picatz/terraform-provider-port-scan/internal/provider/port-scanner"
+)
+
+func Test_latencyStats(t *testing.T) {
+	latencies := []time.Duration{}
+	for i := 20; i >= 1; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+
+	min, avg, p95 := latencyStats(latencies)
+
+	if min != 1 {
+		t.Errorf("Expected min of 1ms, got %v", min)
+	}
+	if avg != 10.5 {
+		t.Errorf("Expected avg of 10.5ms, got %v", avg)
+	}
+	if p95 != 19 {
+		t.Errorf("Expected p95 of 19ms, got %v", p95)
+	}
+
+	if min, avg, p95 := latencyStats(nil); min != 0 || avg != 0 || p95 != 0 {
+		t.Errorf("Expected zero stats without latencies, got %v, %v, %v", min, avg, p95)
+	}
+}
+
+func Test_resultObjects(t *testing.T) {
+	results := []scanner.PortScanResult{
+		{IP: "::1", Port: 22, Open: true, State: scanner.PortStateOpen, Latency: time.Millisecond, Banner: "SSH-2.0-OpenSSH_8.2",
+			Findings: []scanner.Finding{{Prober: "banner", Name: "banner", Value: "SSH-2.0-OpenSSH_8.2"}}},
+		{IP: "::1", Port: 81, State: scanner.PortStateFiltered, Error: context.DeadlineExceeded},
+	}
+
+	objects := resultObjects(results)
+
+	want := []interface{}{
+		map[string]interface{}{
+			"ip_address": "::1",
+			"port":       22,
+			"protocol":   "tcp",
+			"state":      "open",
+			"latency_ms": 1.0,
+			"error":      "",
+			"service":    "ssh",
+			"banner":     "SSH-2.0-OpenSSH_8.2",
+			"probes": []interface{}{
+				map[string]interface{}{"prober": "banner", "name": "banner", "value": "SSH-2.0-OpenSSH_8.2"},
+			},
+		},
+		map[string]interface{}{
+			"ip_address": "::1",
+			"port":       81,
+			"protocol":   "tcp",
+			"state":      "filtered",
+			"latency_ms": 0.0,
+			"error":      context.DeadlineExceeded.Error(),
+			"service":    "",
+			"banner":     "",
+			"probes":     []interface{}{},
+		},
+	}
+
+	if !reflect.DeepEqual(objects, want) {
+		t.Errorf("Expected %v, got %v", want, objects)
+	}
+
+	if endpoints := openEndpoints(results); !reflect.DeepEqual(endpoints, []string{"[::1]:22"}) {
+		t.Errorf("Expected IPv6 endpoints to be bracketed, got %v", endpoints)
+	}
+}
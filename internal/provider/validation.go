@@ -0,0 +1,113 @@
+package provider
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+// hostnameRegexp matches RFC 1123 hostnames.
+var hostnameRegexp = regexp.MustCompile(`^([a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)(\.[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*\.?$`)
+
+// validateTarget checks that a target is an IP address, a CIDR block or a hostname.
+func validateTarget(i interface{}, k string) (warnings []string, errors []error) {
+	v, ok := i.(string)
+	if !ok {
+		errors = append(errors, fmt.Errorf("expected type of %q to be string", k))
+		return
+	}
+
+	switch {
+	case strings.Contains(v, "/"):
+		if _, _, err := net.ParseCIDR(v); err != nil {
+			errors = append(errors, fmt.Errorf("expected %q to be a valid CIDR block, got %q: %s", k, v, err))
+		}
+	case isHost(v):
+	default:
+		errors = append(errors, fmt.Errorf("expected %q to be an IP address, CIDR block or hostname, got %q", k, v))
+	}
+
+	return
+}
+
+// validateHost checks that a single host is an IP address or a hostname.
+func validateHost(i interface{}, k string) (warnings []string, errors []error) {
+	v, ok := i.(string)
+	if !ok {
+		errors = append(errors, fmt.Errorf("expected type of %q to be string", k))
+		return
+	}
+
+	if !isHost(v) {
+		errors = append(errors, fmt.Errorf("expected %q to be an IP address or hostname, got %q", k, v))
+	}
+
+	return
+}
+
+// numericLabelsRegexp matches names whose labels are all numeric, which look
+// like IP addresses, such as "256.256.256.256" or "10.0.0".
+var numericLabelsRegexp = regexp.MustCompile(`^[0-9]+(\.[0-9]+)*\.?$`)
+
+// isHost returns true for IP addresses and hostnames. Names whose labels are
+// all numeric aren't hostnames, but invalid IP addresses.
+func isHost(v string) bool {
+	if net.ParseIP(v) != nil {
+		return true
+	}
+	return len(v) <= 253 && hostnameRegexp.MatchString(v) && !numericLabelsRegexp.MatchString(v)
+}
+
+// resourceGetter is implemented by both *schema.ResourceData and
+// *schema.ResourceDiff, so the same checks can be used in Read functions of
+// data sources, which don't support CustomizeDiff, and in CustomizeDiff
+// functions of resources.
+type resourceGetter interface {
+	Get(key string) interface{}
+	GetOk(key string) (interface{}, bool)
+}
+
+// validatePortRange checks that from_port isn't after to_port, when the range is used.
+func validatePortRange(d resourceGetter) error {
+	if _, ok := d.GetOk("port"); ok {
+		return nil
+	}
+	if _, ok := d.GetOk("ports"); ok {
+		return nil
+	}
+
+	fromPort, toPort := d.Get("from_port").(int), d.Get("to_port").(int)
+	if fromPort > toPort {
+		return fmt.Errorf("from_port (%d) must not be greater than to_port (%d)", fromPort, toPort)
+	}
+
+	return nil
+}
+
+// validateProbes checks the number of ports to scan across all of the hosts
+// against the provider's max_probes limit.
+func (c *providerConfig) validateProbes(hosts, ports int) error {
+	if c.maxProbes > 0 && hosts*ports > c.maxProbes {
+		return fmt.Errorf("scanning %d ports on %d hosts needs %d probes, more than the provider's max_probes limit of %d", ports, hosts, hosts*ports, c.maxProbes)
+	}
+	return nil
+}
+
+// customizeDiffPorts validates the ports of a resource scanning a single
+// ip_address at plan time, once all of the port arguments are known.
+func customizeDiffPorts(d *schema.ResourceDiff, meta interface{}) error {
+	for _, key := range []string{"port", "ports", "from_port", "to_port"} {
+		if !d.NewValueKnown(key) {
+			return nil
+		}
+	}
+
+	if err := validatePortRange(d); err != nil {
+		return err
+	}
+
+	return meta.(*providerConfig).validateProbes(1, len(portsToScan(d)))
+}
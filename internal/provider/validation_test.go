@@ -0,0 +1,124 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+)
+
+func Test_validateTarget(t *testing.T) {
+	for _, target := range []string{"192.168.1.1", "::1", "10.0.0.0/24", "fd00::/120", "example.com", "localhost", "10.example.com"} {
+		if _, errs := validateTarget(target, "targets.0"); len(errs) > 0 {
+			t.Errorf("Expected %q to be valid, got %v", target, errs)
+		}
+	}
+
+	for _, target := range []string{"", "10.0.0.0/33", "not a host", "-example.com", "256.256.256.256", "10.0.0"} {
+		if _, errs := validateTarget(target, "targets.0"); len(errs) == 0 {
+			t.Errorf("Expected %q to be invalid", target)
+		}
+	}
+}
+
+func Test_validateHost(t *testing.T) {
+	for _, host := range []string{"192.168.1.1", "::1", "example.com", "localhost", "10.example.com"} {
+		if _, errs := validateHost(host, "ip_address"); len(errs) > 0 {
+			t.Errorf("Expected %q to be valid, got %v", host, errs)
+		}
+	}
+
+	for _, host := range []string{"", "10.0.0.0/24", "not a host", "-example.com", "256.256.256.256", "10.0.0"} {
+		if _, errs := validateHost(host, "ip_address"); len(errs) == 0 {
+			t.Errorf("Expected %q to be invalid", host)
+		}
+	}
+}
+
+func Test_validatePortRange(t *testing.T) {
+	r := dataSourcePortScan()
+
+	d := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{"from_port": 100, "to_port": 10})
+	if err := validatePortRange(d); err == nil {
+		t.Error("Expected an error when from_port is greater than to_port")
+	}
+
+	d = schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{"from_port": 10, "to_port": 100})
+	if err := validatePortRange(d); err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_validateProbes(t *testing.T) {
+	config := &providerConfig{maxProbes: 1024}
+
+	if err := config.validateProbes(4, 256); err != nil {
+		t.Error(err)
+	}
+	if err := config.validateProbes(4, 257); err == nil {
+		t.Error("Expected an error when the probes exceed max_probes")
+	}
+	if err := (&providerConfig{}).validateProbes(256, 65535); err != nil {
+		t.Errorf("Expected no limit by default, got %v", err)
+	}
+}
+
+func Test_dataSourcePortScan_validate(t *testing.T) {
+	tests := []struct {
+		config map[string]interface{}
+		valid  bool
+	}{
+		{map[string]interface{}{"ip_address": "127.0.0.1", "port": 22}, true},
+		{map[string]interface{}{"ip_address": "127.0.0.1", "ports": []interface{}{22, 80}}, true},
+		{map[string]interface{}{"ip_address": "not an ip", "port": 22}, false},
+		{map[string]interface{}{"ip_address": "example.com", "port": 22}, true},
+		{map[string]interface{}{"ip_address": "10.0.0.0/24", "port": 22}, false},
+		{map[string]interface{}{"ip_address": "127.0.0.1", "to_port": 70000}, false},
+		{map[string]interface{}{"ip_address": "127.0.0.1", "ports": []interface{}{0}}, false},
+		{map[string]interface{}{"ip_address": "127.0.0.1", "port": 22, "ports": []interface{}{80}}, false},
+		{map[string]interface{}{"ip_address": "127.0.0.1", "ports": []interface{}{80}, "from_port": 1}, false},
+		{map[string]interface{}{"ip_address": "127.0.0.1", "rate_limit": 10.0, "host_rate_limit": 0.5}, true},
+		{map[string]interface{}{"ip_address": "127.0.0.1", "rate_limit": -1.0}, false},
+		{map[string]interface{}{"ip_address": "127.0.0.1", "expected_open_ports": []interface{}{70000}}, false},
+		{map[string]interface{}{"ip_address": "127.0.0.1", "expected_closed_ports": []interface{}{0}}, false},
+		{map[string]interface{}{"ip_address": "127.0.0.1", "host_rate_limit": -1.0}, false},
+		{map[string]interface{}{
+			"ip_address":  "127.0.0.1",
+			"ssh_bastion": []interface{}{map[string]interface{}{"ip_address": "10.0.0.1", "password": "secret"}},
+		}, true},
+		{map[string]interface{}{
+			"ip_address":  "127.0.0.1",
+			"ssh_bastion": []interface{}{map[string]interface{}{"ip_address": "10.0.0.1"}},
+		}, false},
+		{map[string]interface{}{
+			"ip_address":  "127.0.0.1",
+			"ssh_bastion": []interface{}{map[string]interface{}{"ip_address": "10.0.0.1", "password": "secret", "private_key": "key"}},
+		}, false},
+	}
+
+	r := dataSourcePortScan()
+	for _, test := range tests {
+		_, errs := r.Validate(terraform.NewResourceConfigRaw(test.config))
+		if test.valid && len(errs) > 0 {
+			t.Errorf("Expected %v to be valid, got %v", test.config, errs)
+		}
+		if !test.valid && len(errs) == 0 {
+			t.Errorf("Expected %v to be invalid", test.config)
+		}
+	}
+}
+
+func Test_resourcePortScanMonitor_customizeDiff(t *testing.T) {
+	r := resourcePortScanMonitor()
+	meta := &providerConfig{maxProbes: 100}
+
+	if _, err := r.Diff(nil, terraform.NewResourceConfigRaw(map[string]interface{}{"ip_address": "127.0.0.1", "to_port": 100}), meta); err != nil {
+		t.Error(err)
+	}
+	if _, err := r.Diff(nil, terraform.NewResourceConfigRaw(map[string]interface{}{"ip_address": "127.0.0.1", "to_port": 101}), meta); err == nil {
+		t.Error("Expected an error when the probes exceed max_probes")
+	}
+	if _, err := r.Diff(nil, terraform.NewResourceConfigRaw(map[string]interface{}{"ip_address": "127.0.0.1", "from_port": 50, "to_port": 10}), meta); err == nil {
+		t.Error("Expected an error when from_port is greater than to_port")
+	}
+}